@@ -0,0 +1,56 @@
+package heartbeat
+
+import (
+	"encoding/json"
+
+	"github.com/fxamacker/cbor/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// Heartbeat payload encodings, configured via
+// DeviceConfiguration.Heartbeat.Encoding. Unset or unrecognized values fall
+// back to EncodingJSON for backward compatibility with operators that don't
+// know about CBOR.
+const (
+	EncodingJSON = "json"
+	EncodingCBOR = "cbor"
+
+	// contentTypeHeader is the gRPC metadata key the chosen codec's
+	// ContentType is advertised under, so the operator side can decode
+	// pb.Data.Content without needing to parse it first.
+	contentTypeHeader = "heartbeat-content-type"
+)
+
+// Codec marshals and unmarshals heartbeat payloads, and advertises the
+// content type of what it produces.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	ContentType() string
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) ContentType() string                        { return "application/json" }
+
+type cborCodec struct{}
+
+func (cborCodec) Marshal(v interface{}) ([]byte, error)      { return cbor.Marshal(v) }
+func (cborCodec) Unmarshal(data []byte, v interface{}) error { return cbor.Unmarshal(data, v) }
+func (cborCodec) ContentType() string                        { return "application/cbor" }
+
+// codecForEncoding resolves the Codec named by encoding, falling back to
+// JSON (with a warning) for an unset or unrecognized value.
+func codecForEncoding(encoding string) Codec {
+	switch encoding {
+	case "", EncodingJSON:
+		return jsonCodec{}
+	case EncodingCBOR:
+		return cborCodec{}
+	default:
+		log.Warnf("unknown heartbeat encoding %q, falling back to JSON", encoding)
+		return jsonCodec{}
+	}
+}
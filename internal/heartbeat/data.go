@@ -0,0 +1,210 @@
+package heartbeat
+
+import (
+	"sync"
+	"time"
+
+	"github.com/project-flotta/flotta-device-worker/internal/ansible"
+	"github.com/project-flotta/flotta-device-worker/internal/configuration"
+	"github.com/project-flotta/flotta-device-worker/internal/datatransfer"
+	"github.com/project-flotta/flotta-device-worker/internal/hardware"
+	os2 "github.com/project-flotta/flotta-device-worker/internal/os"
+	"github.com/project-flotta/flotta-device-worker/internal/workload"
+	"github.com/project-flotta/flotta-operator/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// Hardware reporting scopes, configured via
+// DeviceConfiguration.Heartbeat.HardwareProfile.Scope.
+const (
+	ScopeFull  = "full"
+	ScopeDelta = "delta"
+)
+
+// HeartbeatData assembles a single heartbeat payload out of the device's
+// current workloads, ansible events and hardware facts.
+type HeartbeatData struct {
+	configManager  *configuration.Manager
+	wkManager      *workload.WorkloadManager
+	ansibleManager *ansible.Manager
+	hwManager      hardware.Hardware
+	monitor        *datatransfer.Monitor
+	deviceOs       *os2.OS
+
+	hwLock          sync.Mutex
+	fullHwInfoSent  bool
+	previousMutable *models.HardwareInfo
+}
+
+// NewHeartbeatData creates a HeartbeatData. ansibleManager may be nil, in
+// which case ansible events are omitted from the payload.
+func NewHeartbeatData(configManager *configuration.Manager, wkManager *workload.WorkloadManager,
+	ansibleManager *ansible.Manager, hwManager hardware.Hardware, monitor *datatransfer.Monitor,
+	deviceOs *os2.OS) *HeartbeatData {
+	return &HeartbeatData{
+		configManager:  configManager,
+		wkManager:      wkManager,
+		ansibleManager: ansibleManager,
+		hwManager:      hwManager,
+		monitor:        monitor,
+		deviceOs:       deviceOs,
+	}
+}
+
+// RetrieveInfo builds the next heartbeat payload. Hardware facts are
+// reported in full the first time (or after resetHardwareBaseline), then
+// either as a full mutable-info refresh or a delta against the previous
+// refresh, depending on the configured HardwareProfile.Scope.
+func (h *HeartbeatData) RetrieveInfo() *HeartbeatInfo {
+	info := &HeartbeatInfo{Status: StatusUp}
+
+	workloads, err := h.wkManager.ListWorkloads()
+	if err != nil {
+		log.Errorf("cannot list workloads: %v", err)
+	}
+	info.Workloads = workloads
+
+	var events []*StructuredEvent
+	if h.ansibleManager != nil {
+		events = append(events, ansibleEventsToStructured(h.ansibleManager.PopEvents())...)
+	}
+	events = append(events, Events().Drain()...)
+	info.Events = events
+	info.EventSeq = Events().Seq()
+
+	info.Hardware = h.retrieveHardware("")
+
+	return info
+}
+
+// buildSectionInfo assembles a partial payload carrying only section,
+// reporting hardware at the given scope rather than the device-wide
+// configured one, for use by a TieredScheduler running that section on its
+// own cadence.
+func (h *HeartbeatData) buildSectionInfo(section Section, scope string) *HeartbeatInfo {
+	info := &HeartbeatInfo{Status: StatusUp, Sections: section}
+
+	switch section {
+	case SectionHardware:
+		info.Hardware = h.retrieveHardware(scope)
+
+	case SectionWorkloads:
+		workloads, err := h.wkManager.ListWorkloads()
+		if err != nil {
+			log.Errorf("cannot list workloads: %v", err)
+		}
+		info.Workloads = workloads
+
+	case SectionEvents:
+		var events []*StructuredEvent
+		if h.ansibleManager != nil {
+			events = append(events, ansibleEventsToStructured(h.ansibleManager.PopEvents())...)
+		}
+		events = append(events, Events().Drain()...)
+		info.Events = events
+		info.EventSeq = Events().Seq()
+
+	case SectionOS:
+		// HeartbeatInfo has no dedicated OS section (deviceOs exposes no
+		// reportable facts), so an OS section payload carries only Status
+		// and Sections.
+	}
+
+	return info
+}
+
+// ansibleEventsToStructured adapts the ansible manager's legacy
+// *models.EventInfo queue onto the shared StructuredEvent shape, so
+// ansible-sourced events appear in the same heartbeat Events list as
+// everything emitted through Events().Emit, without requiring the ansible
+// package to depend on this one.
+func ansibleEventsToStructured(legacy []*models.EventInfo) []*StructuredEvent {
+	if len(legacy) == 0 {
+		return nil
+	}
+	out := make([]*StructuredEvent, 0, len(legacy))
+	now := time.Now()
+	for _, e := range legacy {
+		out = append(out, &StructuredEvent{
+			Source:    "ansible",
+			Kind:      "ansible",
+			Severity:  ansibleSeverity(e.Type),
+			Reason:    e.Reason,
+			Message:   e.Message,
+			Count:     1,
+			FirstSeen: now,
+			LastSeen:  now,
+		})
+	}
+	return out
+}
+
+func ansibleSeverity(eventType string) Severity {
+	if eventType == models.EventInfoTypeWarn {
+		return SeverityWarn
+	}
+	return SeverityInfo
+}
+
+// retrieveHardware reports hardware facts at scope, or at the device-wide
+// configured HardwareProfile.Scope if scope is "".
+func (h *HeartbeatData) retrieveHardware(scope string) *models.HardwareInfo {
+	hbCfg := h.configManager.GetDeviceConfiguration().Heartbeat
+	if hbCfg == nil || !hbCfg.HardwareProfile.Include {
+		return nil
+	}
+	if scope == "" {
+		scope = hbCfg.HardwareProfile.Scope
+	}
+
+	h.hwLock.Lock()
+	defer h.hwLock.Unlock()
+
+	if !h.fullHwInfoSent {
+		full, err := h.hwManager.GetHardwareInformation()
+		if err != nil {
+			log.Errorf("cannot retrieve hardware information: %v", err)
+			return nil
+		}
+		if scope == ScopeDelta {
+			baseline, err := h.hwManager.CreateHardwareMutableInformation()
+			if err != nil {
+				log.Errorf("cannot snapshot mutable hardware information: %v", err)
+			} else {
+				h.previousMutable = baseline
+			}
+		}
+		h.fullHwInfoSent = true
+		return full
+	}
+
+	current, err := h.hwManager.CreateHardwareMutableInformation()
+	if err != nil {
+		log.Errorf("cannot retrieve mutable hardware information: %v", err)
+		return nil
+	}
+
+	if scope != ScopeDelta {
+		return current
+	}
+
+	var previous models.HardwareInfo
+	if h.previousMutable != nil {
+		previous = *h.previousMutable
+	}
+	delta := h.hwManager.GetMutableHardwareInfoDelta(previous, *current)
+	h.previousMutable = current
+	return delta
+}
+
+// resetHardwareBaseline forgets the hardware reporting state, so the next
+// RetrieveInfo call reports full hardware facts again. Used when a
+// heartbeat failed to reach the operator, since a delta computed against
+// a baseline the operator never received would leave it with a
+// permanently stale picture.
+func (h *HeartbeatData) resetHardwareBaseline() {
+	h.hwLock.Lock()
+	defer h.hwLock.Unlock()
+	h.fullHwInfoSent = false
+	h.previousMutable = nil
+}
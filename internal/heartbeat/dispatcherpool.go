@@ -0,0 +1,193 @@
+package heartbeat
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	pb "github.com/redhatinsights/yggdrasil/protocol"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+const (
+	initialHealthScore   = 100
+	minHealthScore       = 0
+	maxHealthScore       = 100
+	healthFailurePenalty = 20
+	healthRecoveryCredit = 34
+
+	// failoverHysteresis rate-limits switching the active endpoint, so a
+	// primary that fails, briefly recovers and fails again doesn't
+	// ping-pong against a fallback.
+	failoverHysteresis = 30 * time.Second
+)
+
+// dispatcherEndpoint is one client in a DispatcherPool, tracked by a health
+// score that degrades on failure and recovers on success or a passive
+// GetConfig probe.
+type dispatcherEndpoint struct {
+	name   string
+	client pb.DispatcherClient
+	health int
+}
+
+// DispatcherPool wraps a primary pb.DispatcherClient plus zero or more
+// fallbacks behind the same interface, so it can be passed to
+// NewHeartbeatService in place of a single client with no signature change.
+// Send is tried against the currently-active endpoint; on failure the
+// endpoint's health score is decremented, the healthiest other endpoint is
+// promoted to active (unless the last switch was within failoverHysteresis,
+// in which case the active endpoint stays) and the call is retried against
+// the active endpoint, up to once per endpoint in the pool.
+type DispatcherPool struct {
+	mu         sync.Mutex
+	endpoints  []*dispatcherEndpoint
+	active     int
+	switchedAt time.Time
+}
+
+// NewDispatcherPool builds a DispatcherPool with primary active initially
+// and fallbacks taking over, in order, as endpoints become unhealthy.
+func NewDispatcherPool(primary pb.DispatcherClient, fallbacks ...pb.DispatcherClient) *DispatcherPool {
+	endpoints := make([]*dispatcherEndpoint, 0, 1+len(fallbacks))
+	endpoints = append(endpoints, &dispatcherEndpoint{name: "primary", client: primary, health: initialHealthScore})
+	for i, fb := range fallbacks {
+		endpoints = append(endpoints, &dispatcherEndpoint{
+			name:   fmt.Sprintf("fallback-%d", i+1),
+			client: fb,
+			health: initialHealthScore,
+		})
+	}
+	return &DispatcherPool{endpoints: endpoints}
+}
+
+// ActiveEndpoint returns the name of the endpoint currently serving calls,
+// for exposing as a metric/label.
+func (p *DispatcherPool) ActiveEndpoint() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.endpoints[p.active].name
+}
+
+// HealthScores returns each endpoint's current health score, keyed by name.
+func (p *DispatcherPool) HealthScores() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	scores := make(map[string]int, len(p.endpoints))
+	for _, ep := range p.endpoints {
+		scores[ep.name] = ep.health
+	}
+	return scores
+}
+
+// CheckHealth satisfies HealthChecker by delegating to the active
+// endpoint's own CheckHealth, if it has one.
+func (p *DispatcherPool) CheckHealth(ctx context.Context) error {
+	_, client := p.currentTarget()
+	if checker, ok := client.(HealthChecker); ok {
+		return checker.CheckHealth(ctx)
+	}
+	return nil
+}
+
+func (p *DispatcherPool) Send(ctx context.Context, in *pb.Data, opts ...grpc.CallOption) (*pb.Response, error) {
+	var lastErr error
+	for attempt := 0; attempt < p.endpointCount(); attempt++ {
+		idx, client := p.currentTarget()
+		resp, err := client.Send(ctx, in, opts...)
+		p.recordResult(idx, err)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func (p *DispatcherPool) Register(ctx context.Context, in *pb.RegistrationRequest, opts ...grpc.CallOption) (*pb.RegistrationResponse, error) {
+	var lastErr error
+	for attempt := 0; attempt < p.endpointCount(); attempt++ {
+		idx, client := p.currentTarget()
+		resp, err := client.Register(ctx, in, opts...)
+		p.recordResult(idx, err)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// GetConfig doubles as a passive health probe: a successful call restores
+// health to the endpoint it reached, same as a successful Send.
+func (p *DispatcherPool) GetConfig(ctx context.Context, in *pb.Empty, opts ...grpc.CallOption) (*pb.Config, error) {
+	var lastErr error
+	for attempt := 0; attempt < p.endpointCount(); attempt++ {
+		idx, client := p.currentTarget()
+		cfg, err := client.GetConfig(ctx, in, opts...)
+		p.recordResult(idx, err)
+		if err == nil {
+			return cfg, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func (p *DispatcherPool) endpointCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.endpoints)
+}
+
+func (p *DispatcherPool) currentTarget() (int, pb.DispatcherClient) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.active, p.endpoints[p.active].client
+}
+
+func (p *DispatcherPool) recordResult(idx int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ep := p.endpoints[idx]
+	if err != nil {
+		ep.health -= healthFailurePenalty
+		if ep.health < minHealthScore {
+			ep.health = minHealthScore
+		}
+		if idx == p.active {
+			p.failoverLocked()
+		}
+		return
+	}
+	ep.health += healthRecoveryCredit
+	if ep.health > maxHealthScore {
+		ep.health = maxHealthScore
+	}
+}
+
+// failoverLocked switches to the healthiest other endpoint, if any is
+// strictly healthier than the current one, honoring failoverHysteresis so
+// a flapping primary doesn't ping-pong with a fallback. Must be called
+// with p.mu held.
+func (p *DispatcherPool) failoverLocked() {
+	best := p.active
+	for i, ep := range p.endpoints {
+		if i != p.active && ep.health > p.endpoints[best].health {
+			best = i
+		}
+	}
+	if best == p.active {
+		return
+	}
+	if time.Since(p.switchedAt) < failoverHysteresis {
+		return
+	}
+
+	log.Infof("heartbeat dispatcher failover: switching from %s to %s", p.endpoints[p.active].name, p.endpoints[best].name)
+	p.active = best
+	p.switchedAt = time.Now()
+}
@@ -0,0 +1,225 @@
+package heartbeat
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Severity classifies a structured event for operator triage.
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarn     Severity = "warn"
+	SeverityError    Severity = "error"
+	SeverityCritical Severity = "critical"
+)
+
+const (
+	// eventDedupWindow bounds how long a repeated (source, kind, reason)
+	// event is folded into its existing entry as a count/lastSeen update
+	// instead of appearing as a new one.
+	eventDedupWindow = 5 * time.Minute
+
+	// maxPendingEvents bounds how many distinct events can be queued
+	// between two heartbeat ticks, so a burst can't grow the payload
+	// unbounded.
+	maxPendingEvents = 200
+
+	// Per-source token bucket: eventRateBurst tokens available
+	// immediately, refilling at eventRateBurst per eventRateWindow.
+	eventRateBurst  = 20
+	eventRateWindow = time.Minute
+)
+
+// StructuredEvent is a single deduplicated event emitted by a subsystem
+// (workload, os, datatransfer, hardware, ...) for inclusion in the
+// heartbeat payload. Repeated events with the same Source, Kind and Reason
+// within eventDedupWindow are folded into one entry, incrementing Count and
+// advancing LastSeen rather than appending a duplicate.
+type StructuredEvent struct {
+	Seq       uint64            `json:"seq"`
+	Source    string            `json:"source"`
+	Kind      string            `json:"kind"`
+	Severity  Severity          `json:"severity"`
+	Reason    string            `json:"reason"`
+	Message   string            `json:"message"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	Count     int               `json:"count"`
+	FirstSeen time.Time         `json:"firstSeen"`
+	LastSeen  time.Time         `json:"lastSeen"`
+}
+
+// EventBus is a bounded, deduplicating, rate-limited sink for structured
+// events from any subsystem, drained into the heartbeat payload on each
+// tick. Use the package-level Events accessor to reach the shared instance.
+type EventBus struct {
+	mu       sync.Mutex
+	seq      uint64
+	byKey    map[string]*StructuredEvent
+	pending  []*StructuredEvent
+	limiters map[string]*tokenBucket
+}
+
+func newEventBus() *EventBus {
+	return &EventBus{
+		byKey:    make(map[string]*StructuredEvent),
+		limiters: make(map[string]*tokenBucket),
+	}
+}
+
+var (
+	defaultEventBusOnce sync.Once
+	defaultEventBus     *EventBus
+)
+
+// Events returns the device-wide EventBus shared by every subsystem.
+func Events() *EventBus {
+	defaultEventBusOnce.Do(func() {
+		defaultEventBus = newEventBus()
+	})
+	return defaultEventBus
+}
+
+// Emit records an event from source, identified by kind and reason for
+// deduplication purposes. fields are an optional sequence of key, value
+// pairs attached to the event for operator context. Emit is a no-op if
+// source has exceeded its rate limit.
+func (b *EventBus) Emit(source, kind string, severity Severity, reason, message string, fields ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if !b.allowLocked(source, now) {
+		return
+	}
+
+	key := eventKey(source, kind, reason)
+	if existing, ok := b.byKey[key]; ok && now.Sub(existing.LastSeen) < eventDedupWindow {
+		existing.Count++
+		existing.LastSeen = now
+		existing.Message = message
+		if len(fields) > 0 {
+			existing.Fields = fieldsToMap(fields)
+		}
+		if !b.isPendingLocked(existing) {
+			b.pending = append(b.pending, existing)
+		}
+		return
+	}
+
+	b.seq++
+	event := &StructuredEvent{
+		Seq:       b.seq,
+		Source:    source,
+		Kind:      kind,
+		Severity:  severity,
+		Reason:    reason,
+		Message:   message,
+		Fields:    fieldsToMap(fields),
+		Count:     1,
+		FirstSeen: now,
+		LastSeen:  now,
+	}
+	b.byKey[key] = event
+	b.pending = append(b.pending, event)
+	if len(b.pending) > maxPendingEvents {
+		b.pending = b.pending[len(b.pending)-maxPendingEvents:]
+	}
+}
+
+// Drain returns a snapshot of every event queued since the last Drain,
+// ordered by sequence number, and clears the queue. Deduplication state is
+// retained so a recurring event is still folded into its prior entry on a
+// later Emit.
+func (b *EventBus) Drain() []*StructuredEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.pending) == 0 {
+		return nil
+	}
+	out := make([]*StructuredEvent, len(b.pending))
+	for i, e := range b.pending {
+		cp := *e
+		out[i] = &cp
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Seq < out[j].Seq })
+	b.pending = nil
+	return out
+}
+
+// Seq returns the sequence number of the most recently emitted event, so
+// the operator can detect drops between consecutive heartbeats.
+func (b *EventBus) Seq() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.seq
+}
+
+func (b *EventBus) isPendingLocked(event *StructuredEvent) bool {
+	for _, e := range b.pending {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *EventBus) allowLocked(source string, now time.Time) bool {
+	limiter, ok := b.limiters[source]
+	if !ok {
+		limiter = newTokenBucket(eventRateBurst, eventRateBurst, eventRateWindow)
+		b.limiters[source] = limiter
+	}
+	return limiter.allow(now)
+}
+
+func eventKey(source, kind, reason string) string {
+	return fmt.Sprintf("%s|%s|%s", source, kind, reason)
+}
+
+func fieldsToMap(fields []string) map[string]string {
+	if len(fields) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(fields)/2+1)
+	for i := 0; i+1 < len(fields); i += 2 {
+		out[fields[i]] = fields[i+1]
+	}
+	return out
+}
+
+// tokenBucket is a simple per-source rate limiter: capacity tokens
+// available immediately, refilling at burst tokens per window.
+type tokenBucket struct {
+	capacity   float64
+	refillRate float64 // tokens per second
+	tokens     float64
+	last       time.Time
+}
+
+func newTokenBucket(capacity, burst int, window time.Duration) *tokenBucket {
+	return &tokenBucket{
+		capacity:   float64(capacity),
+		refillRate: float64(burst) / window.Seconds(),
+		tokens:     float64(capacity),
+		last:       time.Now(),
+	}
+}
+
+func (t *tokenBucket) allow(now time.Time) bool {
+	elapsed := now.Sub(t.last).Seconds()
+	t.last = now
+	t.tokens += elapsed * t.refillRate
+	if t.tokens > t.capacity {
+		t.tokens = t.capacity
+	}
+	if t.tokens < 1 {
+		return false
+	}
+	t.tokens--
+	return true
+}
@@ -0,0 +1,554 @@
+package heartbeat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/project-flotta/flotta-device-worker/internal/configuration"
+	"github.com/project-flotta/flotta-device-worker/internal/datatransfer"
+	"github.com/project-flotta/flotta-device-worker/internal/hardware"
+	os2 "github.com/project-flotta/flotta-device-worker/internal/os"
+	"github.com/project-flotta/flotta-device-worker/internal/registration"
+	"github.com/project-flotta/flotta-device-worker/internal/workload"
+	"github.com/project-flotta/flotta-device-worker/internal/workload/api"
+	"github.com/project-flotta/flotta-operator/models"
+	pb "github.com/redhatinsights/yggdrasil/protocol"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDegraded = "degraded"
+
+	ConnectivityOK          = "ok"
+	ConnectivityDegraded    = "degraded"
+	ConnectivityUnreachable = "unreachable"
+
+	heartbeatDirective   = "heartbeat"
+	pingDirective        = "heartbeat-ping"
+	defaultPeriodSeconds = int64(60)
+	tickTimeout          = 10 * time.Second
+
+	// Exponential backoff applied to the ticker period after
+	// backoffThreshold consecutive send failures: period *
+	// backoffFactor^(failures-backoffThreshold+1), capped at
+	// backoffCapFactor*period, plus
+	// jitter, similar to other retryable processing loops in this
+	// codebase backing off instead of tight-looping.
+	backoffThreshold = 3
+	backoffFactor    = 2
+	backoffCapFactor = 10
+
+	// defaultMaxSuppressionFactor bounds how long an unchanged heartbeat
+	// can be suppressed in favor of a ping, as a multiple of currentPeriod,
+	// used when MaxSuppressionInterval isn't set.
+	defaultMaxSuppressionFactor = 5
+)
+
+// heartbeatHashes caches the xxhash of each independently-varying section
+// of the last successfully acked HeartbeatInfo, so a later tick can tell
+// whether anything meaningful actually changed. HeartbeatInfo has no
+// dedicated OS section yet, so Connectivity stands in as the fourth group.
+type heartbeatHashes struct {
+	Workloads    uint64
+	Events       uint64
+	Hardware     uint64
+	Connectivity uint64
+}
+
+func hashInfo(info *HeartbeatInfo) heartbeatHashes {
+	return heartbeatHashes{
+		Workloads:    hashJSON(info.Workloads),
+		Events:       hashJSON(info.Events),
+		Hardware:     hashJSON(info.Hardware),
+		Connectivity: hashJSON(connectivityStatus(info.Connectivity)),
+	}
+}
+
+// connectivityStatus strips RttMs before hashing Connectivity, since it
+// varies tick to tick on a perfectly stable connection and would otherwise
+// defeat suppression - only a Status change should count as meaningful.
+func connectivityStatus(connectivity *ConnectivityInfo) *string {
+	if connectivity == nil {
+		return nil
+	}
+	return &connectivity.Status
+}
+
+func hashJSON(v interface{}) uint64 {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return xxhash.Sum64(payload)
+}
+
+// pingInfo is the lightweight payload sent instead of a full HeartbeatInfo
+// when nothing has changed since the last send within MaxSuppressionInterval.
+// The control plane treats receipt of a ping carrying the last acked hash as
+// equivalent to receiving that full heartbeat again.
+type pingInfo struct {
+	DeviceID string    `json:"deviceId"`
+	Time     time.Time `json:"time"`
+	Hash     uint64    `json:"hash"`
+}
+
+// HealthChecker is implemented by dispatcher clients that expose a
+// dedicated liveness probe distinct from the data-plane Send call,
+// mirroring how yggdrasil separates transport health from delivery.
+// Clients that don't implement it are treated as always healthy.
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// ConnectivityInfo summarizes the outcome of the pre-flight CheckHealth
+// probe run ahead of each heartbeat Send.
+type ConnectivityInfo struct {
+	Status string `json:"status"`
+	RttMs  int64  `json:"rttMs,omitempty"`
+}
+
+// HeartbeatInfo is the payload sent to the operator on each tick, encoded
+// by the configured Codec. A
+// monolithic heartbeat from the single-period Heartbeat ticker populates
+// every section and leaves Sections zero; a partial heartbeat from a
+// TieredScheduler populates only the section(s) named in its Sections
+// bitmap, leaving the rest at their zero value.
+type HeartbeatInfo struct {
+	Status       string               `json:"status"`
+	Sections     Section              `json:"sections,omitempty"`
+	Workloads    []api.WorkloadInfo   `json:"workloads"`
+	Events       []*StructuredEvent   `json:"events,omitempty"`
+	EventSeq     uint64               `json:"eventSeq,omitempty"`
+	Hardware     *models.HardwareInfo `json:"hardware,omitempty"`
+	Connectivity *ConnectivityInfo    `json:"connectivity,omitempty"`
+}
+
+// Heartbeat periodically sends a HeartbeatInfo payload to the operator via
+// a yggdrasil dispatcher client.
+type Heartbeat struct {
+	client        pb.DispatcherClient
+	data          *HeartbeatData
+	configManager *configuration.Manager
+	regWrapper    registration.RegistrationWrapper
+	deviceID      string
+
+	lock                sync.Mutex
+	ticker              *time.Ticker
+	done                chan struct{}
+	started             bool
+	currentPeriod       int64
+	consecutiveFailures int
+
+	spool *spool
+
+	// MaxSuppressionInterval bounds how long an unchanged heartbeat can be
+	// replaced by a lightweight ping, regardless of currentPeriod. Zero
+	// means defaultMaxSuppressionFactor*currentPeriod.
+	MaxSuppressionInterval time.Duration
+
+	hashLock         sync.Mutex
+	lastHashes       heartbeatHashes
+	lastHashesValid  bool
+	lastFullSendTime time.Time
+}
+
+// NewHeartbeatService creates a Heartbeat that reports on wkManager's
+// workloads and hwManager's hardware facts, without ansible events (use
+// NewHeartbeatData directly if those are needed). Every payload is written
+// to an on-disk queue under dataDir ahead of the delivery attempt and acked
+// once the operator confirms receipt, so a crash mid-send or a delivery
+// failure never loses it; undelivered entries are replayed, oldest first,
+// once delivery recovers.
+func NewHeartbeatService(client pb.DispatcherClient, configManager *configuration.Manager,
+	wkManager *workload.WorkloadManager, hwManager hardware.Hardware, monitor *datatransfer.Monitor,
+	deviceOs *os2.OS, regWrapper registration.RegistrationWrapper, dataDir string) *Heartbeat {
+	spool, err := newSpool(filepath.Join(dataDir, "heartbeat"))
+	if err != nil {
+		log.Errorf("cannot initialize heartbeat spool, undelivered heartbeats will be dropped: %v", err)
+	}
+	return &Heartbeat{
+		client:        client,
+		data:          NewHeartbeatData(configManager, wkManager, nil, hwManager, monitor, deviceOs),
+		configManager: configManager,
+		regWrapper:    regWrapper,
+		deviceID:      wkManager.GetDeviceID(),
+		spool:         spool,
+	}
+}
+
+// HasStarted reports whether the heartbeat ticker is currently running.
+func (h *Heartbeat) HasStarted() bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.started
+}
+
+// Start begins sending heartbeats on the period configured in the device
+// configuration. It is a no-op if already started.
+func (h *Heartbeat) Start() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.started {
+		return
+	}
+	h.currentPeriod = h.configuredPeriod()
+	h.startLocked()
+}
+
+// Update applies a new device configuration, starting the heartbeat if it
+// isn't running yet, or resetting its period if it changed.
+func (h *Heartbeat) Update(configuration models.DeviceConfigurationMessage) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	newPeriod := h.periodFromMessage(configuration)
+
+	if !h.started {
+		h.currentPeriod = newPeriod
+		h.startLocked()
+		return nil
+	}
+
+	if newPeriod != h.currentPeriod {
+		log.Infof("Heartbeat configuration update: periodSeconds changed from %d to %d", h.currentPeriod, newPeriod)
+		h.currentPeriod = newPeriod
+		h.ticker.Reset(time.Duration(newPeriod) * time.Second)
+	}
+	return nil
+}
+
+// Deregister stops the heartbeat ticker.
+func (h *Heartbeat) Deregister() error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if !h.started {
+		return nil
+	}
+	h.ticker.Stop()
+	close(h.done)
+	h.started = false
+	return nil
+}
+
+func (h *Heartbeat) startLocked() {
+	h.ticker = time.NewTicker(time.Duration(h.currentPeriod) * time.Second)
+	h.done = make(chan struct{})
+	h.started = true
+	go h.run(h.ticker, h.done)
+}
+
+func (h *Heartbeat) run(ticker *time.Ticker, done chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			h.tick()
+		}
+	}
+}
+
+// codec resolves the payload codec from the current device configuration,
+// so an Update that changes Heartbeat.Encoding takes effect on the next
+// tick without restarting the service.
+func (h *Heartbeat) codec() Codec {
+	hbCfg := h.configManager.GetDeviceConfiguration().Heartbeat
+	if hbCfg == nil {
+		return codecForEncoding("")
+	}
+	return codecForEncoding(hbCfg.Encoding)
+}
+
+// withContentType attaches the codec's content type as outgoing gRPC
+// metadata, so the operator side can select a decoder without first
+// inspecting pb.Data.Content.
+func withContentType(ctx context.Context, codec Codec) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, contentTypeHeader, codec.ContentType())
+}
+
+func (h *Heartbeat) configuredPeriod() int64 {
+	period := h.configManager.GetDeviceConfiguration().Heartbeat.PeriodSeconds
+	if period <= 0 {
+		return defaultPeriodSeconds
+	}
+	return period
+}
+
+func (h *Heartbeat) periodFromMessage(configuration models.DeviceConfigurationMessage) int64 {
+	if configuration.Configuration != nil && configuration.Configuration.Heartbeat != nil &&
+		configuration.Configuration.Heartbeat.PeriodSeconds > 0 {
+		return configuration.Configuration.Heartbeat.PeriodSeconds
+	}
+	return h.configuredPeriod()
+}
+
+// tick retrieves the current heartbeat payload, runs the pre-flight
+// connectivity check, flushes any previously undelivered payloads and
+// sends the new one.
+func (h *Heartbeat) tick() {
+	ctx, cancel := context.WithTimeout(context.Background(), tickTimeout)
+	defer cancel()
+
+	info := h.data.RetrieveInfo()
+	if connectivity := h.checkHealth(ctx); connectivity != nil {
+		info.Connectivity = connectivity
+		if connectivity.Status != ConnectivityOK {
+			info.Status = StatusDegraded
+		}
+	}
+	h.lock.Lock()
+	failing := h.consecutiveFailures > 0
+	h.lock.Unlock()
+	if failing {
+		info.Status = StatusDegraded
+	}
+
+	h.replaySpool(ctx)
+
+	if h.trySendPing(ctx, info) {
+		return
+	}
+	h.send(ctx, info)
+}
+
+// trySendPing sends a lightweight ping instead of info when info's content
+// hashes identically to the last successfully acked heartbeat and that last
+// send happened within MaxSuppressionInterval, to avoid wasting uplink
+// bandwidth on a heartbeat the operator already has. It reports whether it
+// sent a ping, in which case the caller must not also call send.
+func (h *Heartbeat) trySendPing(ctx context.Context, info *HeartbeatInfo) bool {
+	hashes := hashInfo(info)
+
+	h.hashLock.Lock()
+	unchanged := h.lastHashesValid && hashes == h.lastHashes
+	elapsed := time.Since(h.lastFullSendTime)
+	hash := h.lastHashes.Workloads ^ h.lastHashes.Events ^ h.lastHashes.Hardware ^ h.lastHashes.Connectivity
+	h.hashLock.Unlock()
+
+	if !unchanged || elapsed >= h.maxSuppressionInterval() {
+		return false
+	}
+
+	h.sendPing(ctx, hash)
+	return true
+}
+
+func (h *Heartbeat) maxSuppressionInterval() time.Duration {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.MaxSuppressionInterval > 0 {
+		return h.MaxSuppressionInterval
+	}
+	return time.Duration(h.currentPeriod*defaultMaxSuppressionFactor) * time.Second
+}
+
+// sendPing delivers a tiny ping in place of a full heartbeat. A failed ping
+// does not touch the hash cache or the on-disk queue: the next tick will
+// simply try again, either with another ping or, once MaxSuppressionInterval
+// elapses, with the full payload.
+func (h *Heartbeat) sendPing(ctx context.Context, hash uint64) {
+	codec := h.codec()
+	ping := pingInfo{DeviceID: h.deviceID, Time: time.Now(), Hash: hash}
+	payload, err := codec.Marshal(ping)
+	if err != nil {
+		log.Errorf("cannot marshal heartbeat ping: %v", err)
+		return
+	}
+	if _, err := h.client.Send(withContentType(ctx, codec), &pb.Data{Content: payload, Directive: pingDirective}); err != nil {
+		log.Warnf("cannot send heartbeat ping: %v", err)
+	}
+}
+
+// checkHealth runs the dispatcher's dedicated liveness probe, if it has
+// one. Clients without a HealthChecker are assumed healthy, since the
+// actual delivery outcome of Send is still reflected via the Status field.
+func (h *Heartbeat) checkHealth(ctx context.Context) *ConnectivityInfo {
+	checker, ok := h.client.(HealthChecker)
+	if !ok {
+		return nil
+	}
+
+	start := time.Now()
+	err := checker.CheckHealth(ctx)
+	rtt := time.Since(start)
+	if err != nil {
+		log.Warnf("yggdrasil health check failed: %v", err)
+		return &ConnectivityInfo{Status: ConnectivityUnreachable}
+	}
+	return &ConnectivityInfo{Status: ConnectivityOK, RttMs: rtt.Milliseconds()}
+}
+
+// replaySpool delivers any previously spooled heartbeats before the
+// current tick's payload, oldest first. A replayed entry that still fails
+// to send is spooled again by send(), so ordering is preserved across
+// retries.
+func (h *Heartbeat) replaySpool(ctx context.Context) {
+	if h.spool == nil {
+		return
+	}
+	entries, err := h.spool.ReplayAll()
+	if err != nil {
+		log.Errorf("cannot read heartbeat spool: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		h.send(ctx, entry.Info)
+	}
+}
+
+// send writes info to the on-disk queue ahead of the delivery attempt, so a
+// crash mid-send can't lose it, then acks (removes) the queued entry once
+// the operator confirms receipt.
+func (h *Heartbeat) send(ctx context.Context, info *HeartbeatInfo) {
+	entryID := h.enqueueEntry(info)
+
+	codec := h.codec()
+	payload, err := codec.Marshal(info)
+	if err != nil {
+		log.Errorf("cannot marshal heartbeat payload: %v", err)
+		return
+	}
+
+	resp, err := h.client.Send(withContentType(ctx, codec), &pb.Data{Content: payload, Directive: heartbeatDirective})
+	if err != nil {
+		log.Errorf("cannot send heartbeat: %v", err)
+		h.handleSendFailure(info)
+		return
+	}
+	if resp == nil || len(resp.Response) == 0 {
+		log.Tracef("empty response received, host may not be reachable")
+		h.handleSendFailure(info)
+		return
+	}
+	h.ackEntry(entryID)
+	h.recordSentHashes(info)
+	h.handleSendSuccess()
+}
+
+// recordSentHashes caches info's per-section hashes so a future tick can
+// suppress an unchanged heartbeat in favor of a ping. Only called after a
+// successful send, so a failed send (whose entry stays queued for retry)
+// never poisons the cache with content the operator hasn't acked.
+func (h *Heartbeat) recordSentHashes(info *HeartbeatInfo) {
+	h.hashLock.Lock()
+	defer h.hashLock.Unlock()
+	h.lastHashes = hashInfo(info)
+	h.lastHashesValid = true
+	h.lastFullSendTime = time.Now()
+}
+
+func (h *Heartbeat) handleSendFailure(info *HeartbeatInfo) {
+	h.data.resetHardwareBaseline()
+
+	h.lock.Lock()
+	h.consecutiveFailures++
+	h.applyBackoffLocked()
+	h.lock.Unlock()
+}
+
+func (h *Heartbeat) handleSendSuccess() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.consecutiveFailures == 0 {
+		return
+	}
+	h.consecutiveFailures = 0
+	if h.started {
+		h.ticker.Reset(time.Duration(h.currentPeriod) * time.Second)
+	}
+}
+
+// applyBackoffLocked overrides the ticker with an exponentially increasing
+// period once consecutiveFailures crosses backoffThreshold, resetting to
+// currentPeriod on the first subsequent success. Must be called with
+// h.lock held.
+func (h *Heartbeat) applyBackoffLocked() {
+	if h.consecutiveFailures < backoffThreshold || !h.started {
+		return
+	}
+
+	backoffPeriod := h.currentPeriod
+	for i := 0; i < h.consecutiveFailures-backoffThreshold+1; i++ {
+		backoffPeriod *= backoffFactor
+	}
+	if capPeriod := h.currentPeriod * backoffCapFactor; backoffPeriod > capPeriod {
+		backoffPeriod = capPeriod
+	}
+	jitter := time.Duration(rand.Int63n(int64(time.Second))) //nolint:gosec
+	h.ticker.Reset(time.Duration(backoffPeriod)*time.Second + jitter)
+}
+
+// enqueueEntry writes info to the queue ahead of the delivery attempt and
+// returns the ID to later pass to ackEntry, or 0 if there is no queue or
+// the write failed (in which case the payload is only held in memory for
+// this attempt, same as before the queue existed).
+func (h *Heartbeat) enqueueEntry(info *HeartbeatInfo) int64 {
+	if h.spool == nil {
+		return 0
+	}
+	hasFullHardware := info.Hardware != nil && (info.Hardware.CPU != nil || info.Hardware.SystemVendor != nil)
+	scope := ScopeDelta
+	if hbCfg := h.configManager.GetDeviceConfiguration().Heartbeat; hbCfg != nil {
+		scope = hbCfg.HardwareProfile.Scope
+	}
+	entry := spoolEntry{ID: time.Now().UnixNano(), Time: time.Now(), Info: info, HasFullHardware: hasFullHardware, Scope: scope}
+	if err := h.spool.Append(entry); err != nil {
+		log.Errorf("cannot queue heartbeat: %v", err)
+		return 0
+	}
+	return entry.ID
+}
+
+func (h *Heartbeat) ackEntry(id int64) {
+	if h.spool == nil || id == 0 {
+		return
+	}
+	if err := h.spool.Ack(id); err != nil {
+		log.Errorf("cannot ack queued heartbeat: %v", err)
+	}
+}
+
+// Metrics returns the queued/dropped/retried counters for the on-disk
+// heartbeat queue, or a zero value if queuing is disabled.
+func (h *Heartbeat) Metrics() QueueMetrics {
+	if h.spool == nil {
+		return QueueMetrics{}
+	}
+	return h.spool.Metrics()
+}
+
+// Drain flushes any queued heartbeats synchronously, used at graceful
+// shutdown so a pending payload isn't left to wait out the next tick (or a
+// backed-off one) before delivery is attempted one last time. It gives up,
+// leaving remaining entries queued for the next startup, once ctx is done.
+func (h *Heartbeat) Drain(ctx context.Context) error {
+	if h.spool == nil {
+		return nil
+	}
+	entries, err := h.spool.ReplayAll()
+	if err != nil {
+		return fmt.Errorf("cannot read heartbeat queue: %w", err)
+	}
+	for i, entry := range entries {
+		select {
+		case <-ctx.Done():
+			for _, rest := range entries[i:] {
+				if err := h.spool.Append(rest); err != nil {
+					log.Errorf("cannot re-queue heartbeat: %v", err)
+				}
+			}
+			return ctx.Err()
+		default:
+		}
+		h.send(ctx, entry.Info)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package heartbeat
+
+import "testing"
+
+func TestHashInfoIgnoresConnectivityRttMs(t *testing.T) {
+	base := &HeartbeatInfo{Connectivity: &ConnectivityInfo{Status: ConnectivityOK, RttMs: 12}}
+	laterRtt := &HeartbeatInfo{Connectivity: &ConnectivityInfo{Status: ConnectivityOK, RttMs: 340}}
+
+	if hashInfo(base) != hashInfo(laterRtt) {
+		t.Fatalf("expected hashInfo to ignore RttMs so a stable connection still suppresses, got different hashes")
+	}
+}
+
+func TestHashInfoStillReflectsConnectivityStatusChange(t *testing.T) {
+	ok := &HeartbeatInfo{Connectivity: &ConnectivityInfo{Status: ConnectivityOK, RttMs: 12}}
+	degraded := &HeartbeatInfo{Connectivity: &ConnectivityInfo{Status: ConnectivityDegraded, RttMs: 12}}
+
+	if hashInfo(ok) == hashInfo(degraded) {
+		t.Fatalf("expected a Connectivity.Status change to change the hash")
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sync"
 	"time"
@@ -16,6 +18,7 @@ import (
 	"github.com/project-flotta/flotta-device-worker/internal/registration"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/fxamacker/cbor/v2"
 	"github.com/golang/mock/gomock"
 	"github.com/project-flotta/flotta-device-worker/internal/configuration"
 	"github.com/project-flotta/flotta-device-worker/internal/datatransfer"
@@ -25,6 +28,7 @@ import (
 	"github.com/project-flotta/flotta-device-worker/internal/workload/api"
 	"github.com/project-flotta/flotta-operator/models"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 
 	pb "github.com/redhatinsights/yggdrasil/protocol"
 
@@ -73,13 +77,15 @@ var _ = Describe("Heartbeat", func() {
 		ansibleManager, err = ansible.NewAnsibleManager(&client, ansibleDir)
 		Expect(err).NotTo(HaveOccurred(), "Cannot start the Ansible Manager")
 
+		Expect(os.RemoveAll(filepath.Join(datadir, "heartbeat"))).NotTo(HaveOccurred())
 		hb = heartbeat.NewHeartbeatService(&client,
 			configManager,
 			wkManager,
 			hwMock,
 			monitor,
 			deviceOs,
-			regMock)
+			regMock,
+			datadir)
 	})
 
 	AfterEach(func() {
@@ -631,8 +637,289 @@ var _ = Describe("Heartbeat", func() {
 
 	})
 
+	Context("Payload codec", func() {
+		It("Encodes and decodes heartbeats as CBOR when configured", func() {
+			//given
+			wkwMock.EXPECT().List().AnyTimes()
+			initHwMock(hwMock, configManager, "localhost", []string{"127.0.0.1", "0.0.0.0"})
+			clientSuccess := Dispatcher{}
+			hb := createCustomHeartbeatWithDispatcher(&clientSuccess, mockCtrl, datadir, int64(1), wkManager, hwMock, monitor, deviceOs, heartbeat.EncodingCBOR)
+
+			// when
+			hb.Start()
+			time.Sleep(1500 * time.Millisecond)
+			Expect(hb.Deregister()).ToNot(HaveOccurred())
+
+			//then: the fake dispatcher could only have decoded the payload by
+			//honoring the advertised CBOR content type
+			Expect(clientSuccess.GetHwInfoList()).ToNot(BeEmpty())
+		})
+	})
+
+	Context("Offline spool", func() {
+		It("Replays spooled heartbeats once delivery recovers", func() {
+			defer GinkgoRecover()
+			wkwMock.EXPECT().List().AnyTimes()
+
+			spoolDataDir := datadir
+			Expect(os.RemoveAll(filepath.Join(spoolDataDir, "heartbeat"))).NotTo(HaveOccurred())
+
+			regMockFail := registration.NewMockRegistrationWrapper(mockCtrl)
+			configManagerFail := configuration.NewConfigurationManager(spoolDataDir)
+			configManagerFail.GetDeviceConfiguration().Heartbeat.PeriodSeconds = 1
+
+			clientFail := DispatcherFailing{}
+			hbFail := heartbeat.NewHeartbeatService(&clientFail, configManagerFail, wkManager, hwMock, monitor, deviceOs, regMockFail, spoolDataDir)
+
+			// given: several ticks fail to reach the operator and get spooled
+			hbFail.Start()
+			time.Sleep(3500 * time.Millisecond)
+			Expect(hbFail.Deregister()).ToNot(HaveOccurred())
+
+			// every failed send attempt is recorded, replays of earlier
+			// entries included, so this is more than the entries spooled
+			Expect(len(clientFail.GetHwInfoList())).To(BeNumerically(">=", 2))
+
+			// when: a new heartbeat instance over the same data dir recovers
+			regMockSuccess := registration.NewMockRegistrationWrapper(mockCtrl)
+			configManagerSuccess := configuration.NewConfigurationManager(spoolDataDir)
+			configManagerSuccess.GetDeviceConfiguration().Heartbeat.PeriodSeconds = 1
+
+			clientSuccess := Dispatcher{}
+			hbSuccess := heartbeat.NewHeartbeatService(&clientSuccess, configManagerSuccess, wkManager, hwMock, monitor, deviceOs, regMockSuccess, spoolDataDir)
+			hbSuccess.Start()
+			time.Sleep(1500 * time.Millisecond)
+			Expect(hbSuccess.Deregister()).ToNot(HaveOccurred())
+
+			// then: every spooled entry was replayed, plus the new tick's heartbeat
+			spooledCount := int(hbSuccess.Metrics().Retried)
+			Expect(spooledCount).To(BeNumerically(">=", 2))
+			delivered := clientSuccess.GetHwInfoList()
+			Expect(len(delivered)).To(BeNumerically(">=", spooledCount+1))
+		})
+	})
+
+})
+
+var _ = Describe("DispatcherPool", func() {
+
+	It("Fails over to the fallback after the primary starts erroring", func() {
+		// given
+		primary := &DispatcherFailing{}
+		fallback := &Dispatcher{}
+		pool := heartbeat.NewDispatcherPool(primary, fallback)
+		Expect(pool.ActiveEndpoint()).To(Equal("primary"))
+
+		// when: enough failed sends to degrade the primary below the fallback
+		for i := 0; i < 3; i++ {
+			_, _ = pool.Send(context.Background(), &pb.Data{Content: []byte(`{}`)})
+		}
+
+		// then
+		Expect(pool.ActiveEndpoint()).To(Equal("fallback-1"))
+	})
+
+	It("Restores an endpoint's health on a successful GetConfig probe", func() {
+		// given: a single-endpoint pool, so a Send failure degrades health
+		// without anywhere to fail over to
+		primary := &DispatcherFailing{}
+		pool := heartbeat.NewDispatcherPool(primary)
+		_, _ = pool.Send(context.Background(), &pb.Data{Content: []byte(`{}`)})
+		degraded := pool.HealthScores()["primary"]
+
+		// when
+		_, _ = pool.GetConfig(context.Background(), &pb.Empty{})
+
+		// then: GetConfig on DispatcherFailing (which never errors on
+		// GetConfig) counts as a passive health recovery
+		Expect(pool.HealthScores()["primary"]).To(BeNumerically(">", degraded))
+	})
 })
 
+var _ = Describe("Events", func() {
+
+	It("Deduplicates repeated events within the dedup window", func() {
+		// given
+		bus := heartbeat.Events()
+
+		// when
+		bus.Emit("workload", "restart", heartbeat.SeverityWarn, "CrashLoop", "container exited")
+		bus.Emit("workload", "restart", heartbeat.SeverityWarn, "CrashLoop", "container exited again")
+		events := bus.Drain()
+
+		// then
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Count).To(Equal(2))
+		Expect(events[0].Message).To(Equal("container exited again"))
+	})
+
+	It("Assigns monotonically increasing sequence numbers across distinct events", func() {
+		// given
+		bus := heartbeat.Events()
+		bus.Drain()
+
+		// when
+		bus.Emit("hardware", "temperature", heartbeat.SeverityInfo, "Nominal", "cpu temp ok")
+		bus.Emit("os", "update", heartbeat.SeverityCritical, "UpdateFailed", "rpm-ostree rebase failed")
+		events := bus.Drain()
+
+		// then
+		Expect(events).To(HaveLen(2))
+		Expect(events[1].Seq).To(BeNumerically(">", events[0].Seq))
+		Expect(bus.Seq()).To(Equal(events[1].Seq))
+	})
+
+	It("Rate limits a chatty source without affecting others", func() {
+		// given
+		bus := heartbeat.Events()
+		bus.Drain()
+
+		// when: a single source emits far more distinct events than its burst allows
+		for i := 0; i < 100; i++ {
+			bus.Emit("noisy", "spam", heartbeat.SeverityInfo, fmt.Sprintf("Reason%d", i), "spam")
+		}
+		bus.Emit("quiet", "ping", heartbeat.SeverityInfo, "Ping", "ok")
+		events := bus.Drain()
+
+		// then: the noisy source was throttled, but the quiet source got through
+		var noisy, quiet int
+		for _, e := range events {
+			switch e.Source {
+			case "noisy":
+				noisy++
+			case "quiet":
+				quiet++
+			}
+		}
+		Expect(noisy).To(BeNumerically("<", 100))
+		Expect(quiet).To(Equal(1))
+	})
+})
+
+var _ = Describe("TieredScheduler", func() {
+
+	It("does not let a failing hardware section block workload section delivery", func() {
+		// given: a dispatcher that only errors on the hardware section, and a
+		// scheduler running both sections fast enough to observe both fire
+		datadir := "/tmp"
+		mockCtrl := gomock.NewController(GinkgoT())
+		defer mockCtrl.Finish()
+
+		wkwMock := workload.NewMockWorkloadWrapper(mockCtrl)
+		wkwMock.EXPECT().Init().Return(nil).AnyTimes()
+		wkwMock.EXPECT().PersistConfiguration().AnyTimes()
+		wkwMock.EXPECT().List().AnyTimes()
+		wkManager, err := workload.NewWorkloadManagerWithParams(datadir, wkwMock, "device-id-123")
+		Expect(err).NotTo(HaveOccurred(), "Cannot start the Workload Manager")
+
+		hwMock := hardware.NewMockHardware(mockCtrl)
+		hwMock.EXPECT().GetHardwareInformation().Return(&models.HardwareInfo{Hostname: "localhost"}, nil).AnyTimes()
+		hwMock.EXPECT().CreateHardwareMutableInformation().Return(&models.HardwareInfo{Hostname: "localhost"}, nil).AnyTimes()
+
+		configManager := configuration.NewConfigurationManager(datadir)
+		monitor := &datatransfer.Monitor{}
+		gracefulRebootChannel := make(chan struct{})
+		deviceOs := os2.NewOS(gracefulRebootChannel, os2.NewOsExecCommands())
+		regMock := registration.NewMockRegistrationWrapper(mockCtrl)
+
+		Expect(os.RemoveAll(filepath.Join(datadir, "heartbeat"))).NotTo(HaveOccurred())
+
+		client := &DispatcherSectionFailing{failSection: heartbeat.SectionHardware}
+		hb := heartbeat.NewHeartbeatService(client, configManager, wkManager, hwMock, monitor, deviceOs, regMock, datadir)
+
+		scheduler := heartbeat.NewTieredScheduler(hb,
+			heartbeat.SectionConfig{Section: heartbeat.SectionHardware, Period: 200 * time.Millisecond, Scope: heartbeat.ScopeFull},
+			heartbeat.SectionConfig{Section: heartbeat.SectionWorkloads, Period: 200 * time.Millisecond},
+		)
+
+		// when
+		scheduler.Start()
+		time.Sleep(1 * time.Second)
+		scheduler.Stop()
+
+		// then: the hardware section kept erroring, but workload sections
+		// still made it through independently
+		Expect(client.Failures(heartbeat.SectionHardware)).To(BeNumerically(">", 0))
+		Expect(client.Successes(heartbeat.SectionWorkloads)).To(BeNumerically(">", 0))
+	})
+})
+
+// DispatcherSectionFailing errors every Send whose decoded HeartbeatInfo
+// carries failSection in its Sections bitmap, and succeeds otherwise, so
+// tests can assert that one tiered section's failures don't affect
+// another's independent delivery.
+type DispatcherSectionFailing struct {
+	failSection heartbeat.Section
+
+	lock      sync.Mutex
+	failed    map[heartbeat.Section]int
+	succeeded map[heartbeat.Section]int
+}
+
+func (d *DispatcherSectionFailing) Failures(s heartbeat.Section) int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.failed[s]
+}
+
+func (d *DispatcherSectionFailing) Successes(s heartbeat.Section) int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.succeeded[s]
+}
+
+func (d *DispatcherSectionFailing) Send(ctx context.Context, in *pb.Data, opts ...grpc.CallOption) (*pb.Response, error) {
+	var info heartbeat.HeartbeatInfo
+	if err := decodeContent(ctx, in.Content, &info); err != nil {
+		return nil, err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.failed == nil {
+		d.failed = map[heartbeat.Section]int{}
+		d.succeeded = map[heartbeat.Section]int{}
+	}
+
+	if info.Sections&d.failSection != 0 {
+		d.failed[info.Sections]++
+		return nil, fmt.Errorf("section %v failing", info.Sections)
+	}
+
+	d.succeeded[info.Sections]++
+	yggResponse := registration.YGGDResponse{StatusCode: http.StatusOK}
+	response := &pb.Response{}
+	var err error
+	response.Response, err = json.Marshal(yggResponse)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (d *DispatcherSectionFailing) Register(ctx context.Context, in *pb.RegistrationRequest, opts ...grpc.CallOption) (*pb.RegistrationResponse, error) {
+	return nil, nil
+}
+
+func (d *DispatcherSectionFailing) GetConfig(ctx context.Context, in *pb.Empty, opts ...grpc.CallOption) (*pb.Config, error) {
+	return nil, nil
+}
+
+// decodeContent decodes in.Content into v according to the codec the
+// Heartbeat advertised via outgoing gRPC metadata, mirroring how the real
+// operator-side dispatcher would pick a decoder, and defaulting to JSON if
+// no content type was advertised.
+func decodeContent(ctx context.Context, content []byte, v interface{}) error {
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		for _, contentType := range md.Get("heartbeat-content-type") {
+			if contentType == "application/cbor" {
+				return cbor.Unmarshal(content, v)
+			}
+		}
+	}
+	return json.Unmarshal(content, v)
+}
+
 // We keep the latest send data to make sure that we validate the data sent to
 // the operator without sent at all
 type Dispatcher struct {
@@ -659,7 +946,7 @@ func (d *Dispatcher) Send(ctx context.Context, in *pb.Data, opts ...grpc.CallOpt
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	heartbeat := models.Heartbeat{}
-	err := json.Unmarshal(in.Content, &heartbeat)
+	err := decodeContent(ctx, in.Content, &heartbeat)
 	if err != nil {
 		return nil, err
 	}
@@ -702,7 +989,7 @@ func (d *DispatcherEmptyResponse) Send(ctx context.Context, in *pb.Data, opts ..
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	heartbeat := models.Heartbeat{}
-	err := json.Unmarshal(in.Content, &heartbeat)
+	err := decodeContent(ctx, in.Content, &heartbeat)
 	if err != nil {
 		return nil, err
 	}
@@ -738,7 +1025,7 @@ func (d *DispatcherFailing) Send(ctx context.Context, in *pb.Data, opts ...grpc.
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	heartbeat := models.Heartbeat{}
-	err := json.Unmarshal(in.Content, &heartbeat)
+	err := decodeContent(ctx, in.Content, &heartbeat)
 	if err != nil {
 		return nil, err
 	}
@@ -757,10 +1044,17 @@ func (d *DispatcherFailing) GetConfig(ctx context.Context, in *pb.Empty, opts ..
 	return nil, nil
 }
 
-func initHwMock(hwMock *hardware.MockHardware, configManager *configuration.Manager, hostname string, ipv4 []string) (*gomock.Call, *gomock.Call, *gomock.Call) {
+// initHwMock sets up the standard hardware mock expectations used across
+// most tests. An optional encoding (heartbeat.EncodingJSON/EncodingCBOR)
+// parameterizes which codec the resulting heartbeats are sent with,
+// defaulting to JSON.
+func initHwMock(hwMock *hardware.MockHardware, configManager *configuration.Manager, hostname string, ipv4 []string, encoding ...string) (*gomock.Call, *gomock.Call, *gomock.Call) {
 	var m models.HardwareInfo
 	configManager.GetDeviceConfiguration().Heartbeat.HardwareProfile.Scope = heartbeat.ScopeDelta
 	configManager.GetDeviceConfiguration().Heartbeat.HardwareProfile.Include = true
+	if len(encoding) > 0 {
+		configManager.GetDeviceConfiguration().Heartbeat.Encoding = encoding[0]
+	}
 
 	getHardwareInformationCall := hwMock.EXPECT().GetHardwareInformation().Return(&models.HardwareInfo{
 		Hostname: hostname,
@@ -785,19 +1079,29 @@ func initHwMock(hwMock *hardware.MockHardware, configManager *configuration.Mana
 	return getHardwareInformationCall, getMutableHardwareInfoDeltaCall, createHardwareMutableInformationCall
 }
 
-func createCustomHeartbeatWithDispatcher(client pb.DispatcherClient, mockCtrl *gomock.Controller, datadir string, periodSeconds int64, wkManager *workload.WorkloadManager, hwMock *hardware.MockHardware, monitor *datatransfer.Monitor, deviceOs *os2.OS) *heartbeat.Heartbeat {
+// createCustomHeartbeatWithDispatcher builds a Heartbeat wired to client.
+// An optional encoding (heartbeat.EncodingJSON/EncodingCBOR) parameterizes
+// the payload codec, defaulting to JSON.
+func createCustomHeartbeatWithDispatcher(client pb.DispatcherClient, mockCtrl *gomock.Controller, datadir string, periodSeconds int64, wkManager *workload.WorkloadManager, hwMock *hardware.MockHardware, monitor *datatransfer.Monitor, deviceOs *os2.OS, encoding ...string) *heartbeat.Heartbeat {
 	regMock := registration.NewMockRegistrationWrapper(mockCtrl)
 	configManager := configuration.NewConfigurationManager(datadir)
 	configManager.GetDeviceConfiguration().Heartbeat.PeriodSeconds = periodSeconds
 	configManager.GetDeviceConfiguration().Heartbeat.HardwareProfile.Scope = heartbeat.ScopeDelta
 	configManager.GetDeviceConfiguration().Heartbeat.HardwareProfile.Include = true
+	if len(encoding) > 0 {
+		configManager.GetDeviceConfiguration().Heartbeat.Encoding = encoding[0]
+	}
+	if err := os.RemoveAll(filepath.Join(datadir, "heartbeat")); err != nil {
+		panic(err)
+	}
 	return heartbeat.NewHeartbeatService(client,
 		configManager,
 		wkManager,
 		hwMock,
 		monitor,
 		deviceOs,
-		regMock)
+		regMock,
+		datadir)
 }
 
 type Buffer struct {
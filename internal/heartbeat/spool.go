@@ -0,0 +1,211 @@
+package heartbeat
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	spoolFileName = "heartbeat-spool.jsonl"
+
+	// maxSpoolEntries and maxSpoolAge bound the on-disk spool so a
+	// prolonged outage doesn't grow it unbounded.
+	maxSpoolEntries = 50
+	maxSpoolAge     = 24 * time.Hour
+)
+
+// spoolEntry is a single heartbeat written ahead of a client.Send call, so
+// it survives a device-worker crash or restart and can be replayed until
+// acked. HasFullHardware records whether Info carried the full immutable
+// hardware block and Scope the hardware scope in effect; Scope is used to
+// coalesce full-scope entries. Replay does not read HasFullHardware: the
+// hardware baseline in HeartbeatData is reset on every failed send instead.
+type spoolEntry struct {
+	ID              int64          `json:"id"`
+	Time            time.Time      `json:"time"`
+	Info            *HeartbeatInfo `json:"info"`
+	HasFullHardware bool           `json:"hasFullHardware"`
+	Scope           string         `json:"scope"`
+}
+
+// QueueMetrics tracks queue activity for operator visibility: how many
+// entries have been written, how many were evicted before delivery, and
+// how many delivery attempts were retries of a previously queued entry.
+type QueueMetrics struct {
+	Queued  int64 `json:"queued"`
+	Dropped int64 `json:"dropped"`
+	Retried int64 `json:"retried"`
+}
+
+// spool is a bounded, write-ahead on-disk queue of heartbeats pending
+// delivery, replayed in oldest-first (FIFO) order once delivery recovers.
+// Entries are written before client.Send is attempted and acked (removed)
+// once the operator confirms receipt, so a crash mid-send never loses a
+// heartbeat.
+type spool struct {
+	path string
+	mu   sync.Mutex
+
+	queued, dropped, retried int64
+}
+
+func newSpool(dataDir string) (*spool, error) {
+	/* #nosec */
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create heartbeat spool directory: %w", err)
+	}
+	return &spool{path: filepath.Join(dataDir, spoolFileName)}, nil
+}
+
+// Append persists entry, pruning anything beyond maxSpoolEntries or older
+// than maxSpoolAge. A ScopeFull entry replaces every earlier queued
+// ScopeFull entry, keeping only the newest, since a full hardware snapshot
+// makes earlier ones redundant; ScopeDelta entries are all preserved so no
+// delta is lost.
+func (s *spool) Append(entry spoolEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.readAllLocked()
+	if err != nil {
+		return err
+	}
+	if entry.Scope == ScopeFull {
+		kept := entries[:0]
+		for _, e := range entries {
+			if e.Scope != ScopeFull {
+				kept = append(kept, e)
+			}
+		}
+		entries = kept
+	}
+	before := len(entries)
+	entries = append(entries, entry)
+	entries = pruneSpoolEntries(entries)
+	atomic.AddInt64(&s.queued, 1)
+	if dropped := before + 1 - len(entries); dropped > 0 {
+		atomic.AddInt64(&s.dropped, int64(dropped))
+	}
+	return s.writeAllLocked(entries)
+}
+
+// Ack removes the entry with the given ID, since it has been confirmed
+// delivered. It is a no-op if no such entry is queued.
+func (s *spool) Ack(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.readAllLocked()
+	if err != nil {
+		return err
+	}
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.ID != id {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == len(entries) {
+		return nil
+	}
+	return s.writeAllLocked(kept)
+}
+
+// ReplayAll returns all spooled entries oldest-first and clears the spool.
+// Callers that fail to deliver a returned entry are expected to re-Append
+// it (e.g. via the normal write-ahead send path), which is why this method
+// clears the queue up front rather than leaving entries for the caller to
+// Ack individually.
+func (s *spool) ReplayAll() ([]spoolEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.readAllLocked()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	if err := s.writeAllLocked(nil); err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&s.retried, int64(len(entries)))
+	return entries, nil
+}
+
+// Metrics returns a snapshot of the queue's activity counters.
+func (s *spool) Metrics() QueueMetrics {
+	return QueueMetrics{
+		Queued:  atomic.LoadInt64(&s.queued),
+		Dropped: atomic.LoadInt64(&s.dropped),
+		Retried: atomic.LoadInt64(&s.retried),
+	}
+}
+
+func (s *spool) readAllLocked() ([]spoolEntry, error) {
+	/* #nosec */
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []spoolEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var entry spoolEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+func (s *spool) writeAllLocked(entries []spoolEntry) error {
+	tmpPath := s.path + ".tmp"
+	/* #nosec */
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+func pruneSpoolEntries(entries []spoolEntry) []spoolEntry {
+	if len(entries) > maxSpoolEntries {
+		entries = entries[len(entries)-maxSpoolEntries:]
+	}
+	cutoff := time.Now().Add(-maxSpoolAge)
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.Time.After(cutoff) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
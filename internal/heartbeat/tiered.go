@@ -0,0 +1,154 @@
+package heartbeat
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// Section identifies one independently-scheduled part of a heartbeat
+// payload. A TieredScheduler sends a separate HeartbeatInfo per section,
+// each on its own cadence, rather than the single combined payload the
+// monolithic Heartbeat ticker sends.
+type Section int
+
+const (
+	SectionHardware Section = 1 << iota
+	SectionWorkloads
+	SectionEvents
+	SectionOS
+)
+
+// SectionConfig is the cadence at which one Section is reported: every
+// Period, a HeartbeatInfo carrying only that section is sent, with
+// hardware (when Section is SectionHardware) reported at Scope.
+type SectionConfig struct {
+	Section Section
+	Period  time.Duration
+	Scope   string
+}
+
+// sectionTimer is one entry in a TieredScheduler's min-heap, ordered by
+// the next time its section is due to fire.
+type sectionTimer struct {
+	cfg  SectionConfig
+	next time.Time
+}
+
+type timerHeap []*sectionTimer
+
+func (h timerHeap) Len() int            { return len(h) }
+func (h timerHeap) Less(i, j int) bool  { return h[i].next.Before(h[j].next) }
+func (h timerHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *timerHeap) Push(x interface{}) { *h = append(*h, x.(*sectionTimer)) }
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// TieredScheduler sends partial heartbeats for a set of sections, each at
+// its own period, independently of the device-wide Heartbeat ticker. It
+// reuses the owning Heartbeat's write-ahead queue, codec and backoff
+// machinery by calling hb.send for every section fire (a section payload is
+// never replaced by a ping), so a
+// failure in one section's delivery (e.g. hardware retrieval erroring)
+// neither blocks nor is retried by another section's cadence.
+type TieredScheduler struct {
+	hb *Heartbeat
+
+	lock    sync.Mutex
+	timers  timerHeap
+	done    chan struct{}
+	started bool
+}
+
+// NewTieredScheduler builds a TieredScheduler that reports sections
+// against hb. It is opt-in and additive: hb's own monolithic ticker keeps
+// running unless the caller stops it separately.
+func NewTieredScheduler(hb *Heartbeat, sections ...SectionConfig) *TieredScheduler {
+	s := &TieredScheduler{hb: hb}
+	now := time.Now()
+	for _, cfg := range sections {
+		s.timers = append(s.timers, &sectionTimer{cfg: cfg, next: now.Add(cfg.Period)})
+	}
+	heap.Init(&s.timers)
+	return s
+}
+
+// Start begins firing section heartbeats until Stop is called. It is a
+// no-op if already started or if no sections were configured.
+func (s *TieredScheduler) Start() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.started || len(s.timers) == 0 {
+		return
+	}
+	s.started = true
+	s.done = make(chan struct{})
+	go s.run(s.done)
+}
+
+// Stop halts the scheduler. It is safe to call even if never started.
+func (s *TieredScheduler) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.started {
+		return
+	}
+	s.started = false
+	close(s.done)
+}
+
+func (s *TieredScheduler) run(done chan struct{}) {
+	for {
+		s.lock.Lock()
+		if len(s.timers) == 0 {
+			s.lock.Unlock()
+			return
+		}
+		next := s.timers[0]
+		wait := time.Until(next.next)
+		s.lock.Unlock()
+
+		if wait <= 0 {
+			s.fire(next)
+			continue
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+			s.fire(next)
+		case <-done:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// fire sends a heartbeat for t's section and reschedules t for its next
+// period, regardless of whether the send succeeded.
+func (s *TieredScheduler) fire(t *sectionTimer) {
+	s.lock.Lock()
+	t.next = time.Now().Add(t.cfg.Period)
+	heap.Fix(&s.timers, 0)
+	s.lock.Unlock()
+
+	s.fireSection(t.cfg.Section, t.cfg.Scope)
+}
+
+// fireSection builds and sends a single section's payload through the
+// owning Heartbeat's normal send path (write-ahead queue, codec, backoff),
+// without the ping suppression the monolithic ticker applies, so a failure
+// here is retried the same way a full heartbeat failure is.
+func (s *TieredScheduler) fireSection(section Section, scope string) {
+	ctx, cancel := context.WithTimeout(context.Background(), tickTimeout)
+	defer cancel()
+
+	info := s.hb.data.buildSectionInfo(section, scope)
+	s.hb.send(ctx, info)
+}
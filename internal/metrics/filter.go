@@ -0,0 +1,38 @@
+package metrics
+
+// SampleFilter decides whether a scraped sample should be kept, gating both
+// what the local store persists and what remote_write ships for a workload.
+type SampleFilter interface {
+	Allow(sample Sample) bool
+}
+
+// PermissiveAllowList allows every sample through. It's the default filter
+// for a workload that sets no Metrics.AllowList.
+type PermissiveAllowList struct{}
+
+func (*PermissiveAllowList) Allow(Sample) bool {
+	return true
+}
+
+// RestrictiveAllowList only allows samples whose family is explicitly
+// listed, so a workload's Metrics.AllowList caps what gets stored/shipped to
+// just the metric families it names.
+type RestrictiveAllowList struct {
+	allowedFamilies map[string]struct{}
+}
+
+// NewRestrictiveAllowList builds a RestrictiveAllowList from families, the
+// list of metric family names (post-familyOf suffix stripping) a workload
+// wants kept.
+func NewRestrictiveAllowList(families []string) *RestrictiveAllowList {
+	allowed := make(map[string]struct{}, len(families))
+	for _, family := range families {
+		allowed[family] = struct{}{}
+	}
+	return &RestrictiveAllowList{allowedFamilies: allowed}
+}
+
+func (r *RestrictiveAllowList) Allow(sample Sample) bool {
+	_, ok := r.allowedFamilies[sample.Family]
+	return ok
+}
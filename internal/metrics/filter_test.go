@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/project-flotta/flotta-device-worker/internal/metrics/remotewrite"
+)
+
+type fakeShipper struct {
+	pushed []remotewrite.Sample
+}
+
+func newFakeShipper() *fakeShipper {
+	return &fakeShipper{}
+}
+
+func (f *fakeShipper) Push(samples []remotewrite.Sample) {
+	f.pushed = append(f.pushed, samples...)
+}
+
+func TestPermissiveAllowListAllowsEverything(t *testing.T) {
+	var filter SampleFilter = &PermissiveAllowList{}
+	if !filter.Allow(Sample{Family: "anything"}) {
+		t.Fatalf("expected PermissiveAllowList to allow any sample")
+	}
+}
+
+func TestRestrictiveAllowListOnlyAllowsListedFamilies(t *testing.T) {
+	filter := NewRestrictiveAllowList([]string{"cpu_seconds_total"})
+	if !filter.Allow(Sample{Family: "cpu_seconds_total"}) {
+		t.Fatalf("expected listed family to be allowed")
+	}
+	if filter.Allow(Sample{Family: "memory_bytes"}) {
+		t.Fatalf("expected unlisted family to be rejected")
+	}
+}
+
+func TestShipToPushesOnlyAllowedSamplesAndReturnsAllToCaller(t *testing.T) {
+	scraper := func() ([]Sample, error) {
+		return []Sample{
+			{Family: "allowed", Value: 1},
+			{Family: "blocked", Value: 2},
+		}, nil
+	}
+	shipper := newFakeShipper()
+	wrapped := shipTo(scraper, NewRestrictiveAllowList([]string{"allowed"}), shipper)
+
+	samples, err := wrapped()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("expected the wrapper to still return every sample to the caller, got %d", len(samples))
+	}
+	if len(shipper.pushed) != 1 || shipper.pushed[0].Name != "allowed" {
+		t.Fatalf("expected only the allowed sample to be pushed to the shipper, got %v", shipper.pushed)
+	}
+}
@@ -0,0 +1,301 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// acceptHeader negotiates OpenMetrics first, falling back to the classic
+// Prometheus text exposition format for targets that don't understand it.
+const acceptHeader = "application/openmetrics-text;version=1.0.0;q=0.75,text/plain;version=0.0.4;q=0.5"
+
+// familySuffixes are the per-metric-type child suffixes that would
+// otherwise explode a single histogram/summary family into many distinct
+// names when matched literally.
+var familySuffixes = []string{"_bucket", "_count", "_sum", "_created", "_total"}
+
+// familyOf strips a known suffix from name so callers can match an entire
+// metric family (all its bucket/sum/count/created children) in one rule.
+func familyOf(name string) string {
+	for _, suffix := range familySuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+	return name
+}
+
+// ParseScrape parses body according to contentType, using the OpenMetrics
+// parser when the target advertised it and falling back to the legacy
+// parser otherwise.
+func ParseScrape(contentType string, body []byte) ([]Sample, error) {
+	if strings.Contains(contentType, "application/openmetrics-text") {
+		return ParseOpenMetrics(body)
+	}
+	return ParseLegacy(body)
+}
+
+// ParseLegacy parses the classic Prometheus text exposition format: named
+// samples with no family type information and no exemplars.
+func ParseLegacy(body []byte) ([]Sample, error) {
+	return parse(body, false)
+}
+
+// ParseOpenMetrics parses the OpenMetrics text format, preserving each
+// family's declared Type, histogram/summary bucket structure (via the
+// sample's full label set, `le`/`quantile` included), `_created`
+// timestamps, and trailing exemplars.
+func ParseOpenMetrics(body []byte) ([]Sample, error) {
+	return parse(body, true)
+}
+
+func parse(body []byte, openMetrics bool) ([]Sample, error) {
+	types := make(map[string]SampleType)
+	var samples []Sample
+
+	scanner := bufio.NewScanner(strings.NewReader(string(body)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if openMetrics && line == "# EOF" {
+			break
+		}
+		if strings.HasPrefix(line, "# TYPE ") {
+			fields := strings.Fields(line)
+			if len(fields) >= 4 {
+				types[fields[2]] = SampleType(fields[3])
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			// "# HELP ..." and any other comment carry no sample data.
+			continue
+		}
+
+		sample, err := parseSampleLine(line, types, openMetrics)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse scrape line %q: %w", line, err)
+		}
+		samples = append(samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read scrape body: %w", err)
+	}
+	return samples, nil
+}
+
+func parseSampleLine(line string, types map[string]SampleType, openMetrics bool) (Sample, error) {
+	body, exemplar, err := splitExemplar(line, openMetrics)
+	if err != nil {
+		return Sample{}, err
+	}
+
+	name, labels, rest, err := splitNameAndLabels(body)
+	if err != nil {
+		return Sample{}, err
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return Sample{}, fmt.Errorf("missing value")
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return Sample{}, fmt.Errorf("invalid value %q: %w", fields[0], err)
+	}
+
+	var timestamp time.Time
+	if len(fields) > 1 {
+		ts, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return Sample{}, fmt.Errorf("invalid timestamp %q: %w", fields[1], err)
+		}
+		if openMetrics {
+			// OpenMetrics timestamps are fractional seconds since the epoch.
+			timestamp = time.Unix(0, int64(ts*float64(time.Second)))
+		} else {
+			// The classic Prometheus text exposition format's optional
+			// timestamp is milliseconds since the epoch.
+			timestamp = time.Unix(0, int64(ts*float64(time.Millisecond)))
+		}
+	} else {
+		timestamp = time.Now()
+	}
+
+	sampleType := types[familyOf(name)]
+	if sampleType == "" {
+		sampleType = SampleTypeUntyped
+	}
+
+	return Sample{
+		Family:    familyOf(name),
+		Type:      sampleType,
+		Labels:    labels,
+		Value:     value,
+		Timestamp: timestamp,
+		Exemplar:  exemplar,
+	}, nil
+}
+
+// splitExemplar pulls a trailing OpenMetrics exemplar ("# {labels} value
+// [timestamp]") off a sample line, returning the sample portion unchanged
+// and the parsed Exemplar, if any.
+func splitExemplar(line string, openMetrics bool) (string, *Exemplar, error) {
+	if !openMetrics {
+		return line, nil, nil
+	}
+	idx := strings.Index(line, " # {")
+	if idx < 0 {
+		return line, nil, nil
+	}
+
+	sampleBody := line[:idx]
+	exemplarBody := strings.TrimSpace(line[idx+2:])
+
+	_, labels, rest, err := splitNameAndLabels(exemplarBody)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid exemplar: %w", err)
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", nil, fmt.Errorf("exemplar missing value")
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid exemplar value %q: %w", fields[0], err)
+	}
+	var timestamp time.Time
+	if len(fields) > 1 {
+		seconds, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid exemplar timestamp %q: %w", fields[1], err)
+		}
+		timestamp = time.Unix(0, int64(seconds*float64(time.Second)))
+	}
+
+	return sampleBody, &Exemplar{Labels: labels, Value: value, Timestamp: timestamp}, nil
+}
+
+// splitNameAndLabels splits "name{k=\"v\",...} rest" into its metric name,
+// label set and the unparsed remainder (value and optional timestamp).
+func splitNameAndLabels(body string) (string, map[string]string, string, error) {
+	body = strings.TrimSpace(body)
+	braceIdx := strings.IndexByte(body, '{')
+	if braceIdx < 0 {
+		spaceIdx := strings.IndexByte(body, ' ')
+		if spaceIdx < 0 {
+			return "", nil, "", fmt.Errorf("missing value")
+		}
+		return body[:spaceIdx], nil, body[spaceIdx+1:], nil
+	}
+
+	name := body[:braceIdx]
+	closeIdx := strings.IndexByte(body[braceIdx:], '}')
+	if closeIdx < 0 {
+		return "", nil, "", fmt.Errorf("unterminated label set")
+	}
+	closeIdx += braceIdx
+
+	labels, err := parseLabels(body[braceIdx+1 : closeIdx])
+	if err != nil {
+		return "", nil, "", err
+	}
+	return name, labels, strings.TrimSpace(body[closeIdx+1:]), nil
+}
+
+// parseLabels parses a `key="value",key2="value2"` label body. It tokenizes
+// on commas outside of quotes, since both OpenMetrics and the legacy
+// exposition format allow a quoted label value to contain a literal comma
+// (e.g. `path="/a,b"`), and unescapes `\"`, `\\` and `\n` inside values per
+// both formats' escaping rules.
+func parseLabels(raw string) (map[string]string, error) {
+	labels := make(map[string]string)
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return labels, nil
+	}
+	for _, pair := range splitLabelPairs(raw) {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		eqIdx := strings.IndexByte(pair, '=')
+		if eqIdx < 0 {
+			return nil, fmt.Errorf("invalid label %q", pair)
+		}
+		key := strings.TrimSpace(pair[:eqIdx])
+		rawValue := strings.TrimSpace(pair[eqIdx+1:])
+		value, err := unquoteLabelValue(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label %q: %w", pair, err)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// splitLabelPairs splits raw on top-level commas, treating anything between
+// a pair of unescaped double quotes as opaque so a comma inside a label
+// value doesn't end the pair early.
+func splitLabelPairs(raw string) []string {
+	var pairs []string
+	inQuotes := false
+	escaped := false
+	start := 0
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && inQuotes:
+			escaped = true
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == ',' && !inQuotes:
+			pairs = append(pairs, raw[start:i])
+			start = i + 1
+		}
+	}
+	pairs = append(pairs, raw[start:])
+	return pairs
+}
+
+// unquoteLabelValue strips the surrounding double quotes from a label value
+// and unescapes `\"`, `\\` and `\n`, per the OpenMetrics/legacy exposition
+// format escaping rules.
+func unquoteLabelValue(raw string) (string, error) {
+	if len(raw) < 2 || raw[0] != '"' || raw[len(raw)-1] != '"' {
+		return "", fmt.Errorf("value must be double-quoted")
+	}
+	body := raw[1 : len(raw)-1]
+
+	var b strings.Builder
+	b.Grow(len(body))
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i >= len(body) {
+			return "", fmt.Errorf("trailing backslash")
+		}
+		switch body[i] {
+		case '"':
+			b.WriteByte('"')
+		case '\\':
+			b.WriteByte('\\')
+		case 'n':
+			b.WriteByte('\n')
+		default:
+			return "", fmt.Errorf("invalid escape sequence \\%c", body[i])
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLabelsQuotedComma(t *testing.T) {
+	labels, err := parseLabels(`path="/a,b",method="GET"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if labels["path"] != "/a,b" {
+		t.Fatalf("expected path %q, got %q", "/a,b", labels["path"])
+	}
+	if labels["method"] != "GET" {
+		t.Fatalf("expected method %q, got %q", "GET", labels["method"])
+	}
+}
+
+func TestParseLabelsEscapes(t *testing.T) {
+	labels, err := parseLabels(`msg="say \"hi\"\nnext line",path="C:\\tmp"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if labels["msg"] != "say \"hi\"\nnext line" {
+		t.Fatalf("unexpected msg label: %q", labels["msg"])
+	}
+	if labels["path"] != `C:\tmp` {
+		t.Fatalf("unexpected path label: %q", labels["path"])
+	}
+}
+
+func TestParseSampleLineLegacyTimestampIsMilliseconds(t *testing.T) {
+	sample, err := parseSampleLine("foo 1 1000", map[string]SampleType{}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := time.Unix(0, 1000*int64(time.Millisecond))
+	if !sample.Timestamp.Equal(want) {
+		t.Fatalf("expected timestamp %v, got %v", want, sample.Timestamp)
+	}
+}
+
+func TestParseSampleLineOpenMetricsTimestampIsFractionalSeconds(t *testing.T) {
+	sample, err := parseSampleLine("foo 1 1.5", map[string]SampleType{}, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := time.Unix(0, int64(1.5*float64(time.Second)))
+	if !sample.Timestamp.Equal(want) {
+		t.Fatalf("expected timestamp %v, got %v", want, sample.Timestamp)
+	}
+}
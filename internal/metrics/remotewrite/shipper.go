@@ -0,0 +1,323 @@
+package remotewrite
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultCapacity          = 10000
+	defaultMaxSamplesPerSend = 500
+	defaultMinBackoff        = 1 * time.Second
+	defaultMaxBackoff        = 30 * time.Second
+	defaultBatchSendDeadline = 5 * time.Second
+	defaultMaxRetries        = 5
+
+	remoteWriteVersionHeader = "X-Prometheus-Remote-Write-Version"
+	remoteWriteVersion       = "0.1.0"
+)
+
+// Sample is one timestamped metric value shipped to a remote_write
+// endpoint, carrying the same label set PermissiveAllowList/
+// RestrictiveAllowList filter on upstream.
+type Sample struct {
+	Name      string
+	Labels    map[string]string
+	Value     float64
+	Timestamp time.Time
+}
+
+// TLSConfig mirrors metrics.TLSConfig so the remote_write endpoint can be
+// reached over mTLS without this package depending on the metrics package.
+type TLSConfig struct {
+	CABundlePath       string
+	ClientCertFile     string
+	ClientKeyFile      string
+	InsecureSkipVerify bool
+}
+
+// Authorization mirrors metrics.Authorization for the same reason.
+type Authorization struct {
+	BearerTokenFile       string
+	BasicAuthUsernameFile string
+	BasicAuthPasswordFile string
+}
+
+// QueueConfig bounds how Shipper buffers and batches samples before a send.
+type QueueConfig struct {
+	Capacity          int
+	MaxSamplesPerSend int
+	MinBackoff        time.Duration
+	MaxBackoff        time.Duration
+	BatchSendDeadline time.Duration
+}
+
+func (q QueueConfig) withDefaults() QueueConfig {
+	if q.Capacity <= 0 {
+		q.Capacity = defaultCapacity
+	}
+	if q.MaxSamplesPerSend <= 0 {
+		q.MaxSamplesPerSend = defaultMaxSamplesPerSend
+	}
+	if q.MinBackoff <= 0 {
+		q.MinBackoff = defaultMinBackoff
+	}
+	if q.MaxBackoff <= 0 {
+		q.MaxBackoff = defaultMaxBackoff
+	}
+	if q.BatchSendDeadline <= 0 {
+		q.BatchSendDeadline = defaultBatchSendDeadline
+	}
+	return q
+}
+
+// Config is everything a Shipper needs to reach one remote_write endpoint.
+type Config struct {
+	URL           string
+	Headers       map[string]string
+	TLSConfig     *TLSConfig
+	Authorization *Authorization
+	QueueConfig   QueueConfig
+}
+
+// Shipper batches filtered samples into Prometheus remote_write requests
+// and POSTs them to a single endpoint, retrying with exponential backoff on
+// failure and dropping the batch (counted, never blocking the scrape
+// pipeline) once retries are exhausted.
+type Shipper struct {
+	cfg    Config
+	client *http.Client
+
+	queue chan Sample
+	done  chan struct{}
+	wg    sync.WaitGroup
+
+	droppedSamples uint64
+}
+
+// NewShipper builds a Shipper for cfg. It does not start shipping until
+// Start is called.
+func NewShipper(cfg Config) (*Shipper, error) {
+	cfg.QueueConfig = cfg.QueueConfig.withDefaults()
+	client, err := httpClientFor(cfg.TLSConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot build remote_write client for %s: %w", cfg.URL, err)
+	}
+	return &Shipper{
+		cfg:    cfg,
+		client: client,
+		queue:  make(chan Sample, cfg.QueueConfig.Capacity),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Start begins the batching/send loop in the background.
+func (s *Shipper) Start() {
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop halts the send loop. Samples already queued are dropped.
+func (s *Shipper) Stop() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// Push enqueues samples for the next batch send. A full queue drops the
+// incoming samples that don't fit, counting them as dropped, rather than
+// blocking the scrape pipeline that's calling Push.
+func (s *Shipper) Push(samples []Sample) {
+	for _, sample := range samples {
+		select {
+		case s.queue <- sample:
+		default:
+			atomic.AddUint64(&s.droppedSamples, 1)
+		}
+	}
+}
+
+// DroppedSamples reports how many samples have been dropped so far, either
+// because the queue was full or because a batch exhausted its retries. It's
+// meant to be exported as a self-metric by the caller.
+func (s *Shipper) DroppedSamples() uint64 {
+	return atomic.LoadUint64(&s.droppedSamples)
+}
+
+func (s *Shipper) run() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.cfg.QueueConfig.BatchSendDeadline)
+	defer ticker.Stop()
+
+	var batch []Sample
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.sendWithRetry(batch); err != nil {
+			log.Warnf("dropping remote_write batch of %d samples to %s after retries exhausted: %v", len(batch), s.cfg.URL, err)
+			atomic.AddUint64(&s.droppedSamples, uint64(len(batch)))
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case sample := <-s.queue:
+			batch = append(batch, sample)
+			if len(batch) >= s.cfg.QueueConfig.MaxSamplesPerSend {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.done:
+			flush()
+			return
+		}
+	}
+}
+
+func (s *Shipper) sendWithRetry(batch []Sample) error {
+	backoff := s.cfg.QueueConfig.MinBackoff
+	var lastErr error
+	for attempt := 0; attempt < defaultMaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > s.cfg.QueueConfig.MaxBackoff {
+				backoff = s.cfg.QueueConfig.MaxBackoff
+			}
+		}
+		if lastErr = s.send(batch); lastErr == nil {
+			return nil
+		}
+	}
+	return lastErr
+}
+
+func (s *Shipper) send(batch []Sample) error {
+	req := &prompb.WriteRequest{Timeseries: toTimeseries(batch)}
+	body, err := req.Marshal()
+	if err != nil {
+		return fmt.Errorf("cannot marshal remote_write request: %w", err)
+	}
+	encoded := snappy.Encode(nil, body)
+
+	httpReq, err := http.NewRequest(http.MethodPost, s.cfg.URL, bytes.NewReader(encoded))
+	if err != nil {
+		return fmt.Errorf("cannot build remote_write request: %w", err)
+	}
+	httpReq.Header.Set("Content-Encoding", "snappy")
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set(remoteWriteVersionHeader, remoteWriteVersion)
+	for key, value := range s.cfg.Headers {
+		httpReq.Header.Set(key, value)
+	}
+	if err := authorize(httpReq, s.cfg.Authorization); err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("cannot POST remote_write batch to %s: %w", s.cfg.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("remote_write POST to %s returned status %d", s.cfg.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+func toTimeseries(batch []Sample) []prompb.TimeSeries {
+	series := make([]prompb.TimeSeries, 0, len(batch))
+	for _, sample := range batch {
+		labels := make([]prompb.Label, 0, len(sample.Labels)+1)
+		for name, value := range sample.Labels {
+			labels = append(labels, prompb.Label{Name: name, Value: value})
+		}
+		// Remote_write series identity is the hash of the label set, so the
+		// labels must come out in the same (sorted) order on every call;
+		// map iteration order is randomized and would otherwise fragment
+		// one logical series into several as far as the receiver is
+		// concerned.
+		sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+		labels = append([]prompb.Label{{Name: "__name__", Value: sample.Name}}, labels...)
+		series = append(series, prompb.TimeSeries{
+			Labels: labels,
+			Samples: []prompb.Sample{{
+				Value:     sample.Value,
+				Timestamp: sample.Timestamp.UnixNano() / int64(time.Millisecond),
+			}},
+		})
+	}
+	return series
+}
+
+func httpClientFor(cfg *TLSConfig) (*http.Client, error) {
+	if cfg == nil {
+		return http.DefaultClient, nil
+	}
+
+	tlsCfg := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify} //#nosec
+
+	if cfg.CABundlePath != "" {
+		caBundle, err := ioutil.ReadFile(cfg.CABundlePath) //#nosec
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA bundle %s: %w", cfg.CABundlePath, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", cfg.CABundlePath)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsCfg}}, nil
+}
+
+func authorize(req *http.Request, auth *Authorization) error {
+	if auth == nil {
+		return nil
+	}
+	if auth.BearerTokenFile != "" {
+		token, err := ioutil.ReadFile(auth.BearerTokenFile) //#nosec
+		if err != nil {
+			return fmt.Errorf("cannot read bearer token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+		return nil
+	}
+	if auth.BasicAuthUsernameFile != "" || auth.BasicAuthPasswordFile != "" {
+		username, err := ioutil.ReadFile(auth.BasicAuthUsernameFile) //#nosec
+		if err != nil {
+			return fmt.Errorf("cannot read basic auth username: %w", err)
+		}
+		password, err := ioutil.ReadFile(auth.BasicAuthPasswordFile) //#nosec
+		if err != nil {
+			return fmt.Errorf("cannot read basic auth password: %w", err)
+		}
+		req.SetBasicAuth(strings.TrimSpace(string(username)), strings.TrimSpace(string(password)))
+	}
+	return nil
+}
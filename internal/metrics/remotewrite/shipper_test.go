@@ -0,0 +1,36 @@
+package remotewrite
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestToTimeseriesSortsLabelsByName(t *testing.T) {
+	sample := Sample{
+		Name: "http_requests_total",
+		Labels: map[string]string{
+			"zone":   "eu",
+			"method": "GET",
+			"code":   "200",
+			"path":   "/v1/things",
+		},
+		Value:     1,
+		Timestamp: time.Unix(0, 0),
+	}
+
+	for i := 0; i < 20; i++ {
+		series := toTimeseries([]Sample{sample})
+		if len(series) != 1 {
+			t.Fatalf("expected 1 series, got %d", len(series))
+		}
+		labels := series[0].Labels
+		if labels[0].Name != "__name__" {
+			t.Fatalf("expected __name__ first, got %q", labels[0].Name)
+		}
+		rest := labels[1:]
+		if !sort.SliceIsSorted(rest, func(i, j int) bool { return rest[i].Name < rest[j].Name }) {
+			t.Fatalf("labels not sorted by name: %v", rest)
+		}
+	}
+}
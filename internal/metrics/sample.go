@@ -0,0 +1,42 @@
+package metrics
+
+import "time"
+
+// SampleType is an OpenMetrics metric family type. The classic Prometheus
+// text format doesn't carry this information per-sample, so samples parsed
+// by the legacy parser are always SampleTypeUntyped.
+type SampleType string
+
+const (
+	SampleTypeCounter   SampleType = "counter"
+	SampleTypeGauge     SampleType = "gauge"
+	SampleTypeHistogram SampleType = "histogram"
+	SampleTypeSummary   SampleType = "summary"
+	SampleTypeInfo      SampleType = "info"
+	SampleTypeStateSet  SampleType = "stateset"
+	SampleTypeUntyped   SampleType = "untyped"
+)
+
+// Exemplar attaches a trace reference to a sample, per the OpenMetrics
+// exemplar syntax ("# {trace_id=\"...\",span_id=\"...\"} value timestamp").
+type Exemplar struct {
+	Labels    map[string]string
+	Value     float64
+	Timestamp time.Time
+}
+
+// Sample is one parsed metric observation. Family is the metric name with
+// any `_bucket`/`_count`/`_sum`/`_created`/`_total` suffix stripped, so
+// PermissiveAllowList/RestrictiveAllowList can match on the family as a
+// whole instead of every histogram bucket child individually. The full,
+// unstripped name is not kept: Labels holds only the sample's own label set
+// (e.g. `le` for a bucket), so children such as `_sum` and `_count` of one
+// family are not told apart.
+type Sample struct {
+	Family    string
+	Type      SampleType
+	Labels    map[string]string
+	Value     float64
+	Timestamp time.Time
+	Exemplar  *Exemplar
+}
@@ -7,6 +7,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/project-flotta/flotta-device-worker/internal/metrics/remotewrite"
 	"github.com/project-flotta/flotta-device-worker/internal/workload/podman"
 	"github.com/project-flotta/flotta-operator/models"
 )
@@ -19,10 +20,13 @@ type WorkloadMetrics struct {
 	daemon         MetricsDaemon
 	workloadConfig map[string]*models.Workload
 	lock           sync.RWMutex
+
+	shippersLock sync.Mutex
+	shippers     map[string]*remotewrite.Shipper
 }
 
 func NewWorkloadMetrics(daemon MetricsDaemon) *WorkloadMetrics {
-	return &WorkloadMetrics{daemon: daemon}
+	return &WorkloadMetrics{daemon: daemon, shippers: make(map[string]*remotewrite.Shipper)}
 }
 
 func (wrkM *WorkloadMetrics) getWorkload(workloadName string) *models.Workload {
@@ -50,6 +54,16 @@ func (wrkM *WorkloadMetrics) Update(config models.DeviceConfigurationMessage) er
 func (wrkM *WorkloadMetrics) WorkloadRemoved(workloadName string) {
 	log.Infof("Removing target metrics for workload '%v'", workloadName)
 	wrkM.daemon.DeleteTarget(workloadName)
+	wrkM.unregisterShipper(workloadName)
+}
+
+func (wrkM *WorkloadMetrics) unregisterShipper(workloadName string) {
+	wrkM.shippersLock.Lock()
+	defer wrkM.shippersLock.Unlock()
+	if shipper, ok := wrkM.shippers[workloadName]; ok {
+		shipper.Stop()
+		delete(wrkM.shippers, workloadName)
+	}
 }
 
 func (wrkM *WorkloadMetrics) WorkloadStarted(workloadName string, report []*podman.PodReport) {
@@ -70,17 +84,139 @@ func (wrkM *WorkloadMetrics) WorkloadStarted(workloadName string, report []*podm
 			filter = NewRestrictiveAllowList(cfg.Metrics.AllowList)
 		}
 
-		urls := []string{}
+		targets := []ScrapeTarget{}
 		for _, workloadReport := range report {
-			urls = append(urls, getWorkloadUrls(workloadReport, cfg)...)
+			targets = append(targets, getScrapeTargets(workloadReport, cfg)...)
 		}
 
 		interval := defaultInterval
 		if cfg.Metrics.Interval > 0 {
 			interval = cfg.Metrics.Interval
 		}
+
+		shipper := wrkM.registerShipper(workload.Name, cfg.Metrics.RemoteWrite)
+		scraper := CreateHTTPScraper(targets)
+		if shipper != nil {
+			scraper = shipTo(scraper, filter, shipper)
+		}
 		// log for this is part of the AddTarget function
-		wrkM.daemon.AddTarget(workload.Name, CreateHTTPScraper(urls), time.Duration(interval)*time.Second, filter)
+		wrkM.daemon.AddTarget(workload.Name, scraper, time.Duration(interval)*time.Second, filter)
+	}
+}
+
+// sampleSink is the subset of *remotewrite.Shipper that shipTo needs, kept
+// as its own interface so tests can exercise the wiring with a fake instead
+// of a live Shipper.
+type sampleSink interface {
+	Push(samples []remotewrite.Sample)
+}
+
+// shipTo wraps scraper so that, on top of returning every scraped sample to
+// the caller unchanged (for the local store's own pipeline), it also pushes
+// the subset filter allows to sink - the same allow-list that gates what
+// the local store keeps, so remote_write never ships more than a workload's
+// own config permits.
+func shipTo(scraper Scraper, filter SampleFilter, sink sampleSink) Scraper {
+	return func() ([]Sample, error) {
+		samples, err := scraper()
+		if err != nil {
+			return samples, err
+		}
+
+		var allowed []remotewrite.Sample
+		for _, sample := range samples {
+			if filter.Allow(sample) {
+				allowed = append(allowed, toRemoteWriteSample(sample))
+			}
+		}
+		if len(allowed) > 0 {
+			sink.Push(allowed)
+		}
+		return samples, nil
+	}
+}
+
+func toRemoteWriteSample(sample Sample) remotewrite.Sample {
+	return remotewrite.Sample{
+		Name:      sample.Family,
+		Labels:    sample.Labels,
+		Value:     sample.Value,
+		Timestamp: sample.Timestamp,
+	}
+}
+
+// registerShipper starts (replacing any previous one) a remote_write
+// Shipper for workloadName when remoteWrite is configured, or tears down a
+// stale one when it isn't, so WorkloadStarted stays idempotent across
+// config updates. It returns the registered Shipper, or nil when remoteWrite
+// is nil.
+func (wrkM *WorkloadMetrics) registerShipper(workloadName string, remoteWrite *models.RemoteWriteConfig) *remotewrite.Shipper {
+	wrkM.unregisterShipper(workloadName)
+	if remoteWrite == nil {
+		return nil
+	}
+
+	shipper, err := remotewrite.NewShipper(toRemoteWriteConfig(remoteWrite))
+	if err != nil {
+		log.Errorf("cannot create remote_write shipper for workload '%s': %v", workloadName, err)
+		return nil
+	}
+	shipper.Start()
+
+	wrkM.shippersLock.Lock()
+	wrkM.shippers[workloadName] = shipper
+	wrkM.shippersLock.Unlock()
+	return shipper
+}
+
+// Shipper returns the remote_write Shipper registered for workloadName, if
+// any, e.g. so callers can export its DroppedSamples count as a self-metric.
+func (wrkM *WorkloadMetrics) Shipper(workloadName string) (*remotewrite.Shipper, bool) {
+	wrkM.shippersLock.Lock()
+	defer wrkM.shippersLock.Unlock()
+	shipper, ok := wrkM.shippers[workloadName]
+	return shipper, ok
+}
+
+func toRemoteWriteConfig(cfg *models.RemoteWriteConfig) remotewrite.Config {
+	rwCfg := remotewrite.Config{
+		URL:           cfg.URL,
+		Headers:       cfg.Headers,
+		TLSConfig:     toRemoteWriteTLSConfig(cfg.TLSConfig),
+		Authorization: toRemoteWriteAuthorization(cfg.Authorization),
+	}
+	if q := cfg.QueueConfig; q != nil {
+		rwCfg.QueueConfig = remotewrite.QueueConfig{
+			Capacity:          int(q.Capacity),
+			MaxSamplesPerSend: int(q.MaxSamplesPerSend),
+			MinBackoff:        time.Duration(q.MinBackoffSeconds) * time.Second,
+			MaxBackoff:        time.Duration(q.MaxBackoffSeconds) * time.Second,
+			BatchSendDeadline: time.Duration(q.BatchSendDeadlineSeconds) * time.Second,
+		}
+	}
+	return rwCfg
+}
+
+func toRemoteWriteTLSConfig(cfg *models.MetricsTLSConfig) *remotewrite.TLSConfig {
+	if cfg == nil {
+		return nil
+	}
+	return &remotewrite.TLSConfig{
+		CABundlePath:       cfg.CABundlePath,
+		ClientCertFile:     cfg.ClientCertFile,
+		ClientKeyFile:      cfg.ClientKeyFile,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+}
+
+func toRemoteWriteAuthorization(auth *models.MetricsAuthorization) *remotewrite.Authorization {
+	if auth == nil {
+		return nil
+	}
+	return &remotewrite.Authorization{
+		BearerTokenFile:       auth.BearerTokenFile,
+		BasicAuthUsernameFile: auth.BasicAuthUsernameFile,
+		BasicAuthPasswordFile: auth.BasicAuthPasswordFile,
 	}
 }
 
@@ -88,29 +224,64 @@ func (wrKM *WorkloadMetrics) String() string {
 	return "workload metrics"
 }
 
-func getWorkloadUrls(report *podman.PodReport, config *models.Workload) []string {
-	res := []string{}
-	metricsPath := config.Metrics.Path
-	port := config.Metrics.Port
+// getScrapeTargets builds one ScrapeTarget per container exposing metrics,
+// carrying whatever scheme/TLS/authorization settings apply to it: the
+// container's own override when workload.Metrics.Containers sets one,
+// otherwise the workload-level defaults.
+func getScrapeTargets(report *podman.PodReport, config *models.Workload) []ScrapeTarget {
+	res := []ScrapeTarget{}
+	metrics := config.Metrics
 	for _, container := range report.Containers {
-		if customConfig, ok := config.Metrics.Containers[container.Name]; ok {
+		if customConfig, ok := metrics.Containers[container.Name]; ok {
 			if customConfig.Disabled {
 				continue
 			}
-			res = append(res,
-				fmt.Sprintf("http://%s:%d%s",
-					container.IPAddress, customConfig.Port,
-					getPathOrDefault(customConfig.Path)))
+			res = append(res, newScrapeTarget(
+				container.IPAddress, customConfig.Port, customConfig.Path,
+				firstNonEmptyScheme(customConfig.Scheme, metrics.Scheme),
+				firstNonNilTLSConfig(customConfig.TLSConfig, metrics.TLSConfig),
+				firstNonNilAuthorization(customConfig.Authorization, metrics.Authorization)))
 		} else {
-			res = append(res,
-				fmt.Sprintf("http://%s:%d%s",
-					container.IPAddress, port,
-					getPathOrDefault(metricsPath)))
+			res = append(res, newScrapeTarget(
+				container.IPAddress, metrics.Port, metrics.Path,
+				metrics.Scheme, metrics.TLSConfig, metrics.Authorization))
 		}
 	}
 	return res
 }
 
+func newScrapeTarget(ip string, port int32, path, scheme string, tlsConfig *models.MetricsTLSConfig, auth *models.MetricsAuthorization) ScrapeTarget {
+	if scheme == "" {
+		scheme = "http"
+	}
+	return ScrapeTarget{
+		URL:           fmt.Sprintf("%s://%s:%d%s", scheme, ip, port, getPathOrDefault(path)),
+		TLSConfig:     toTLSConfig(tlsConfig),
+		Authorization: toAuthorization(auth),
+	}
+}
+
+func firstNonEmptyScheme(containerScheme, workloadScheme string) string {
+	if containerScheme != "" {
+		return containerScheme
+	}
+	return workloadScheme
+}
+
+func firstNonNilTLSConfig(containerTLS, workloadTLS *models.MetricsTLSConfig) *models.MetricsTLSConfig {
+	if containerTLS != nil {
+		return containerTLS
+	}
+	return workloadTLS
+}
+
+func firstNonNilAuthorization(containerAuth, workloadAuth *models.MetricsAuthorization) *models.MetricsAuthorization {
+	if containerAuth != nil {
+		return containerAuth
+	}
+	return workloadAuth
+}
+
 func getPathOrDefault(path string) string {
 	if path == "" {
 		return "/"
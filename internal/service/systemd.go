@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/coreos/go-systemd/v22/dbus"
@@ -32,6 +33,39 @@ const (
 	SystemBus BusType = "system"
 )
 
+// Schedule describes a systemd.timer trigger that runs a workload's .service
+// unit on a cron-like schedule instead of keeping it running continuously.
+// At least one of the fields must be set; unset fields are omitted from the
+// generated timer unit.
+type Schedule struct {
+	OnCalendar      string `json:"onCalendar,omitempty"`
+	OnBootSec       string `json:"onBootSec,omitempty"`
+	OnUnitActiveSec string `json:"onUnitActiveSec,omitempty"`
+}
+
+func (sch Schedule) empty() bool {
+	return sch.OnCalendar == "" && sch.OnBootSec == "" && sch.OnUnitActiveSec == ""
+}
+
+func (sch Schedule) timerDirectives() []string {
+	var lines []string
+	if sch.OnCalendar != "" {
+		lines = append(lines, "OnCalendar="+sch.OnCalendar)
+	}
+	if sch.OnBootSec != "" {
+		lines = append(lines, "OnBootSec="+sch.OnBootSec)
+	}
+	if sch.OnUnitActiveSec != "" {
+		lines = append(lines, "OnUnitActiveSec="+sch.OnUnitActiveSec)
+	}
+	return lines
+}
+
+func renderTimerUnit(name string, sch Schedule) string {
+	return fmt.Sprintf("[Unit]\nDescription=Timer for %s\n\n[Timer]\n%s\nUnit=%s\nPersistent=true\n\n[Install]\nWantedBy=timers.target\n",
+		name, strings.Join(sch.timerDirectives(), "\n"), DefaultServiceName(name))
+}
+
 //go:generate mockgen -package=service -destination=mock_systemd.go . Service
 type Service interface {
 	GetName() string
@@ -41,14 +75,21 @@ type Service interface {
 	Stop() error
 	Enable() error
 	Disable() error
+	// StartTimer, StopTimer, EnableTimer and DisableTimer manage the paired
+	// <name>.timer unit for a service created with a Schedule. They are
+	// no-ops when the service was not created with a Schedule.
+	StartTimer() error
+	StopTimer() error
+	EnableTimer() error
+	DisableTimer() error
 }
 
 type systemd struct {
-	Name           string            `json:"name"`
-	Units          []string          `json:"units"`
-	UnitsContent   map[string]string `json:"-"`
-	dbusConnection *dbus.Conn        `json:"-"`
-	BusType        BusType           `json:"busType"`
+	Name         string            `json:"name"`
+	Units        []string          `json:"units"`
+	UnitsContent map[string]string `json:"-"`
+	BusType      BusType           `json:"busType"`
+	Schedule     *Schedule         `json:"schedule,omitempty"`
 }
 
 //go:generate mockgen -package=service -destination=mock_systemd_manager.go . SystemdManager
@@ -57,6 +98,12 @@ type SystemdManager interface {
 	Get(name string) Service
 	Remove(svc Service) error
 	RemoveServicesFile() error
+	// StartMany and StopMany start/stop several services concurrently over
+	// the shared dbus connection, instead of the caller serializing one
+	// Start/Stop call per service. The returned map only contains entries
+	// for services that failed.
+	StartMany(svcs []Service) map[string]error
+	StopMany(svcs []Service) map[string]error
 }
 
 type systemdManager struct {
@@ -121,6 +168,37 @@ func (mgr *systemdManager) Remove(svc Service) error {
 	return mgr.write()
 }
 
+func (mgr *systemdManager) StartMany(svcs []Service) map[string]error {
+	return runConcurrently(svcs, Service.Start)
+}
+
+func (mgr *systemdManager) StopMany(svcs []Service) map[string]error {
+	return runConcurrently(svcs, Service.Stop)
+}
+
+// runConcurrently calls op on each service in parallel and collects the
+// failures, keyed by service name.
+func runConcurrently(svcs []Service, op func(Service) error) map[string]error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failures := make(map[string]error)
+
+	for _, svc := range svcs {
+		wg.Add(1)
+		go func(svc Service) {
+			defer wg.Done()
+			if err := op(svc); err != nil {
+				mu.Lock()
+				failures[svc.GetName()] = err
+				mu.Unlock()
+			}
+		}(svc)
+	}
+	wg.Wait()
+
+	return failures
+}
+
 func (mgr *systemdManager) write() error {
 	svcJson, err := json.Marshal(mgr.services)
 	if err != nil {
@@ -129,6 +207,79 @@ func (mgr *systemdManager) write() error {
 	return os.WriteFile(mgr.svcFilePath, svcJson, 0640) //#nosec
 }
 
+// connPool lazily opens and shares a single long-lived dbus connection per
+// BusType, instead of every Start/Stop/Enable/Disable/reload call dialing
+// (and, for the user bus, re-authenticating) its own connection. Dropped
+// connections are detected lazily: a call that fails with a disconnect-like
+// error invalidates the cached connection so the next caller redials.
+type connPool struct {
+	mu    sync.Mutex
+	conns map[BusType]*dbus.Conn
+}
+
+var pool = &connPool{conns: make(map[BusType]*dbus.Conn)}
+
+func (p *connPool) get(busType BusType) (*dbus.Conn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if conn, ok := p.conns[busType]; ok {
+		return conn, nil
+	}
+
+	conn, err := newDbusConnection(busType)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[busType] = conn
+	return conn, nil
+}
+
+// invalidate drops conn from the pool if it is still the cached connection
+// for busType, closing it so a subsequent get redials.
+func (p *connPool) invalidate(busType BusType, conn *dbus.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if cached, ok := p.conns[busType]; ok && cached == conn {
+		cached.Close()
+		delete(p.conns, busType)
+	}
+}
+
+// isDisconnectErr reports whether err looks like the dbus connection itself
+// was dropped, as opposed to the call simply failing.
+func isDisconnectErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "EOF") ||
+		strings.Contains(msg, "closed network connection") ||
+		strings.Contains(msg, "use of closed") ||
+		strings.Contains(msg, "disconnected")
+}
+
+// withConn runs fn against the pooled connection for busType, retrying once
+// against a freshly-dialed connection if fn fails with a disconnect error.
+func withConn(busType BusType, fn func(conn *dbus.Conn) error) error {
+	conn, err := pool.get(busType)
+	if err != nil {
+		return err
+	}
+
+	err = fn(conn)
+	if isDisconnectErr(err) {
+		pool.invalidate(busType, conn)
+		conn, err = pool.get(busType)
+		if err != nil {
+			return err
+		}
+		err = fn(conn)
+	}
+	return err
+}
+
 func newDbusConnection(busType BusType) (*dbus.Conn, error) {
 	if busType == UserBus {
 		return dbus.NewConnection(func() (*godbus.Conn, error) {
@@ -158,11 +309,17 @@ func newDbusConnection(busType BusType) (*dbus.Conn, error) {
 }
 
 func NewSystemd(name string, units map[string]string, busType BusType) (Service, error) {
-	var err error
-	var conn *dbus.Conn
+	return NewScheduledSystemd(name, units, busType, nil)
+}
 
-	conn, err = newDbusConnection(busType)
-	if err != nil {
+// NewScheduledSystemd creates a Service the same way NewSystemd does, but when
+// schedule is non-nil it also renders and manages a paired <name>.timer unit
+// that triggers the <name>.service on the given schedule, instead of the
+// service being expected to run continuously.
+func NewScheduledSystemd(name string, units map[string]string, busType BusType, schedule *Schedule) (Service, error) {
+	// Prime (and validate) the shared connection for this bus up front, so
+	// construction still fails fast when dbus is unreachable.
+	if _, err := pool.get(busType); err != nil {
 		return nil, err
 	}
 
@@ -171,12 +328,16 @@ func NewSystemd(name string, units map[string]string, busType BusType) (Service,
 		unitNames = append(unitNames, unit)
 	}
 
+	if schedule != nil && schedule.empty() {
+		schedule = nil
+	}
+
 	return &systemd{
-		Name:           name,
-		dbusConnection: conn,
-		Units:          unitNames,
-		BusType:        busType,
-		UnitsContent:   units,
+		Name:         name,
+		Units:        unitNames,
+		BusType:      busType,
+		UnitsContent: units,
+		Schedule:     schedule,
 	}, nil
 }
 
@@ -186,103 +347,156 @@ func (s *systemd) Add() error {
 	}
 
 	for unit, content := range s.UnitsContent {
-		targetPath := path.Join(DefaultUnitsPath, DefaultServiceName(unit))
+		targetPath := path.Join(DefaultUnitsPath, unitFileName(unit))
 		err := os.WriteFile(targetPath, []byte(content), 0644) //#nosec
 		if err != nil {
 			return err
 		}
 		log.Infof("writing new systemd file for '%s' on '%s'", unit, targetPath)
 	}
+
+	if s.Schedule != nil {
+		timerPath := path.Join(DefaultUnitsPath, DefaultTimerName(s.Name))
+		if err := os.WriteFile(timerPath, []byte(renderTimerUnit(s.Name, *s.Schedule)), 0644); err != nil { //#nosec
+			return err
+		}
+		log.Infof("writing new systemd timer file for '%s' on '%s'", s.Name, timerPath)
+	}
+
 	return s.reload()
 }
 
 func (s *systemd) Remove() error {
 	for _, unit := range s.Units {
-		err := os.Remove(path.Join(DefaultUnitsPath, DefaultServiceName(unit)))
+		err := os.Remove(path.Join(DefaultUnitsPath, unitFileName(unit)))
 		if err != nil {
 			return err
 		}
 	}
+
+	if s.Schedule != nil {
+		if err := os.Remove(path.Join(DefaultUnitsPath, DefaultTimerName(s.Name))); err != nil {
+			return err
+		}
+	}
+
 	return s.reload()
 }
 
+// unitFileName returns the filename a unit's content should be written to:
+// unit names that are already suffixed with .service or .timer are used
+// as-is, everything else is assumed to be a plain service name.
+func unitFileName(unit string) string {
+	if strings.HasSuffix(unit, ServiceSuffix) || strings.HasSuffix(unit, TimerSuffix) {
+		return unit
+	}
+	return DefaultServiceName(unit)
+}
+
 func (s *systemd) GetName() string {
 	return s.Name
 }
 
 func (s *systemd) reload() error {
-	conn, err := newDbusConnection(s.BusType)
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-	return conn.ReloadContext(context.Background())
+	return withConn(s.BusType, func(conn *dbus.Conn) error {
+		return conn.ReloadContext(context.Background())
+	})
 }
 
 func (s *systemd) Start() error {
-	log.Debugf("Starting systemd service %s", s.Name)
-	conn, err := newDbusConnection(s.BusType)
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-	startChan := make(chan string)
-	if _, err := conn.StartUnitContext(context.Background(), DefaultServiceName(s.Name), "replace", startChan); err != nil {
-		return err
-	}
+	return s.startUnit(DefaultServiceName(s.Name))
+}
+
+func (s *systemd) Stop() error {
+	return s.stopUnit(DefaultServiceName(s.Name))
+}
+
+func (s *systemd) Enable() error {
+	return s.enableUnit(DefaultServiceName(s.Name))
+}
+
+func (s *systemd) Disable() error {
+	return s.disableUnit(DefaultServiceName(s.Name))
+}
 
-	result := <-startChan
-	switch result {
-	case "done":
+func (s *systemd) StartTimer() error {
+	if s.Schedule == nil {
 		return nil
-	default:
-		return errors.Errorf("Failed[%s] to start systemd service %s", result, DefaultServiceName(s.Name))
 	}
+	return s.startUnit(DefaultTimerName(s.Name))
 }
 
-func (s *systemd) Stop() error {
-	conn, err := newDbusConnection(s.BusType)
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-	stopChan := make(chan string)
-	if _, err := conn.StopUnitContext(context.Background(), DefaultServiceName(s.Name), "replace", stopChan); err != nil {
-		return err
+func (s *systemd) StopTimer() error {
+	if s.Schedule == nil {
+		return nil
 	}
+	return s.stopUnit(DefaultTimerName(s.Name))
+}
 
-	result := <-stopChan
-	switch result {
-	case "done":
+func (s *systemd) EnableTimer() error {
+	if s.Schedule == nil {
 		return nil
-	default:
-		return errors.Errorf("Failed[%s] to stop systemd service %s", result, DefaultServiceName(s.Name))
 	}
+	return s.enableUnit(DefaultTimerName(s.Name))
 }
 
-func (s *systemd) Enable() error {
-	log.Debugf("Enabling systemd service %s", s.Name)
-	conn, err := newDbusConnection(s.BusType)
-	if err != nil {
-		return err
+func (s *systemd) DisableTimer() error {
+	if s.Schedule == nil {
+		return nil
 	}
-	defer conn.Close()
+	return s.disableUnit(DefaultTimerName(s.Name))
+}
 
-	_, _, err = conn.EnableUnitFilesContext(context.Background(), []string{DefaultServiceName(s.Name)}, false, true)
-	return err
+func (s *systemd) enableUnit(unitName string) error {
+	log.Debugf("Enabling systemd unit %s", unitName)
+	return withConn(s.BusType, func(conn *dbus.Conn) error {
+		_, _, err := conn.EnableUnitFilesContext(context.Background(), []string{unitName}, false, true)
+		return err
+	})
 }
 
-func (s *systemd) Disable() error {
-	log.Debugf("Disabling systemd service %s", s.Name)
-	conn, err := newDbusConnection(s.BusType)
-	if err != nil {
+func (s *systemd) disableUnit(unitName string) error {
+	log.Debugf("Disabling systemd unit %s", unitName)
+	return withConn(s.BusType, func(conn *dbus.Conn) error {
+		_, err := conn.DisableUnitFilesContext(context.Background(), []string{unitName}, false)
 		return err
-	}
-	defer conn.Close()
-	_, err = conn.DisableUnitFilesContext(context.Background(), []string{DefaultServiceName(s.Name)}, false)
-	return err
+	})
+}
+
+func (s *systemd) startUnit(unitName string) error {
+	log.Debugf("Starting systemd unit %s", unitName)
+	return withConn(s.BusType, func(conn *dbus.Conn) error {
+		startChan := make(chan string, 1)
+		if _, err := conn.StartUnitContext(context.Background(), unitName, "replace", startChan); err != nil {
+			return err
+		}
+		result := <-startChan
+		if result != "done" {
+			return errors.Errorf("Failed[%s] to start systemd unit %s", result, unitName)
+		}
+		return nil
+	})
+}
+
+func (s *systemd) stopUnit(unitName string) error {
+	log.Debugf("Stopping systemd unit %s", unitName)
+	return withConn(s.BusType, func(conn *dbus.Conn) error {
+		stopChan := make(chan string, 1)
+		if _, err := conn.StopUnitContext(context.Background(), unitName, "replace", stopChan); err != nil {
+			return err
+		}
+		result := <-stopChan
+		if result != "done" {
+			return errors.Errorf("Failed[%s] to stop systemd unit %s", result, unitName)
+		}
+		return nil
+	})
 }
 
 func DefaultServiceName(serviceName string) string {
 	return serviceName + ServiceSuffix
 }
+
+func DefaultTimerName(serviceName string) string {
+	return serviceName + TimerSuffix
+}
@@ -0,0 +1,39 @@
+package volumes
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// HostPathVolume returns the pod volume that exposes workloadName's
+// host-backed "export" directory under volumesDir to the workload's
+// containers.
+func HostPathVolume(volumesDir, workloadName string) v1.Volume {
+	hostPathType := v1.HostPathDirectoryOrCreate
+	return v1.Volume{
+		Name: exportVolumeName(workloadName),
+		VolumeSource: v1.VolumeSource{
+			HostPath: &v1.HostPathVolumeSource{
+				Path: HostPathVolumePath(volumesDir, workloadName),
+				Type: &hostPathType,
+			},
+		},
+	}
+}
+
+// HostPathVolumePath returns the host directory backing workloadName's
+// export volume.
+func HostPathVolumePath(volumesDir, workloadName string) string {
+	return path.Join(volumesDir, sanitizeName(workloadName))
+}
+
+func exportVolumeName(workloadName string) string {
+	return fmt.Sprintf("export-%s", sanitizeName(workloadName))
+}
+
+func sanitizeName(name string) string {
+	return strings.ReplaceAll(name, " ", "-")
+}
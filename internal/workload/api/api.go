@@ -0,0 +1,11 @@
+package api
+
+// WorkloadInfo describes a single running/stopped workload as reported by
+// the underlying container runtime.
+type WorkloadInfo struct {
+	Id        string
+	Name      string
+	Status    string
+	IPAddress string          `json:",omitempty"`
+	Health    *WorkloadHealth `json:",omitempty"`
+}
@@ -0,0 +1,57 @@
+package api
+
+import "time"
+
+// Health states mirror the Docker/Podman healthcheck state machine.
+const (
+	HealthStarting  = "starting"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// maxProbeHistory bounds the rolling window of probe results kept per
+// workload so the heartbeat payload doesn't grow unbounded over the
+// workload's lifetime.
+const maxProbeHistory = 5
+
+// ProbeResult is a single healthcheck execution outcome.
+type ProbeResult struct {
+	Time    time.Time
+	Success bool
+	Output  string
+}
+
+// WorkloadHealth is the structured healthcheck state reported for a
+// workload, derived from its containers' healthcheck specs.
+type WorkloadHealth struct {
+	State   string
+	History []ProbeResult
+}
+
+// NewWorkloadHealth returns the initial health block for a workload whose
+// probes haven't run yet.
+func NewWorkloadHealth() *WorkloadHealth {
+	return &WorkloadHealth{State: HealthStarting}
+}
+
+// Record appends a probe outcome, trimming the rolling window to
+// maxProbeHistory, and updates the derived state.
+func (h *WorkloadHealth) Record(result ProbeResult) {
+	h.History = append(h.History, result)
+	if len(h.History) > maxProbeHistory {
+		h.History = h.History[len(h.History)-maxProbeHistory:]
+	}
+	if result.Success {
+		h.State = HealthHealthy
+	} else {
+		h.State = HealthUnhealthy
+	}
+}
+
+// Last returns the most recent probe result, or false if none have run yet.
+func (h *WorkloadHealth) Last() (ProbeResult, bool) {
+	if len(h.History) == 0 {
+		return ProbeResult{}, false
+	}
+	return h.History[len(h.History)-1], true
+}
@@ -0,0 +1,122 @@
+package workload
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/project-flotta/flotta-device-worker/internal/service"
+)
+
+const (
+	podmanAutoUpdateUnitName = "podman-auto-update"
+
+	ReasonImageUpdated      = "ImageUpdated"
+	ReasonImageUpdateFailed = "ImageUpdateFailed"
+	ReasonImageRolledBack   = "ImageRolledBack"
+)
+
+// AutoUpdateResult is a single per-container row of `podman auto-update`'s output.
+type AutoUpdateResult struct {
+	Unit      string
+	Container string
+	Image     string
+	Policy    string
+	Updated   string
+}
+
+// EnableAutoUpdateTimer installs and starts the podman-auto-update.timer unit
+// via systemd, with OnUnitActiveSec set from the device's configured
+// interval, so that image auto-update runs on a schedule without the device
+// worker having to manage its own ticker.
+func (w *WorkloadManager) EnableAutoUpdateTimer(mgr service.SystemdManager, interval string) error {
+	svc, err := service.NewScheduledSystemd(podmanAutoUpdateUnitName, map[string]string{}, service.UserBus,
+		&service.Schedule{OnUnitActiveSec: interval})
+	if err != nil {
+		return err
+	}
+	if err := svc.Add(); err != nil {
+		return err
+	}
+	if err := mgr.Add(svc); err != nil {
+		return err
+	}
+	if err := svc.EnableTimer(); err != nil {
+		return err
+	}
+	return svc.StartTimer()
+}
+
+// AutoUpdate triggers `podman auto-update` for the given per-workload
+// authfile (or the default credential store when authFile is empty), parses
+// the per-container result rows and pushes the corresponding events into the
+// events queue so they get reported on the next heartbeat. Podman itself
+// performs the rollback (re-tag and restart of the previous image) when a
+// new image fails its healthcheck; AutoUpdate only surfaces that outcome.
+func (w *WorkloadManager) AutoUpdate(authFile string) ([]AutoUpdateResult, error) {
+	args := []string{"auto-update", "--format", "{{.Unit}}\t{{.Container}}\t{{.Image}}\t{{.Policy}}\t{{.Updated}}"}
+	if authFile != "" {
+		args = append(args, "--authfile", authFile)
+	}
+
+	cmd := exec.Command("podman", args...) //#nosec
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	runErr := cmd.Run()
+
+	results := parseAutoUpdateOutput(stdout.String())
+
+	for _, result := range results {
+		reason, eventType, message := autoUpdateEvent(result)
+		if reason == "" {
+			continue
+		}
+		w.emitEvent(result.Container, eventType, reason, message)
+	}
+
+	if runErr != nil {
+		return results, fmt.Errorf("podman auto-update failed: %w", runErr)
+	}
+	return results, nil
+}
+
+func autoUpdateEvent(result AutoUpdateResult) (reason string, eventType EventType, message string) {
+	switch strings.ToLower(result.Updated) {
+	case "true", "pending":
+		return ReasonImageUpdated, EventTypeNormal,
+			fmt.Sprintf("container %s updated to image %s", result.Container, result.Image)
+	case "rolled back":
+		return ReasonImageRolledBack, EventTypeWarning,
+			fmt.Sprintf("container %s failed healthcheck after update, rolled back to previous image %s", result.Container, result.Image)
+	case "failed":
+		return ReasonImageUpdateFailed, EventTypeWarning,
+			fmt.Sprintf("container %s failed to update to image %s", result.Container, result.Image)
+	default:
+		return "", "", ""
+	}
+}
+
+func parseAutoUpdateOutput(output string) []AutoUpdateResult {
+	var results []AutoUpdateResult
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			continue
+		}
+		results = append(results, AutoUpdateResult{
+			Unit:      fields[0],
+			Container: fields[1],
+			Image:     fields[2],
+			Policy:    fields[3],
+			Updated:   fields[4],
+		})
+	}
+	return results
+}
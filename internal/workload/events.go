@@ -0,0 +1,121 @@
+package workload
+
+import (
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+)
+
+// EventType mirrors the two-level severity Kubernetes events use.
+type EventType string
+
+const (
+	EventTypeNormal  EventType = "Normal"
+	EventTypeWarning EventType = "Warning"
+)
+
+// Well-known event reasons emitted by the WorkloadManager at the sites that
+// used to just return an error through multierror without recording
+// anything a caller could act on. ReasonWorkloadRemoved is defined but no
+// WorkloadManager code path emits it.
+const (
+	ReasonWorkloadStartFailed   = "WorkloadStartFailed"
+	ReasonWorkloadRemoved       = "WorkloadRemoved"
+	ReasonWorkloadRolledBack    = "WorkloadRolledBack"
+	ReasonSecretSyncFailed      = "SecretSyncFailed"
+	ReasonManifestWriteFailed   = "ManifestWriteFailed"
+	ReasonMountValidationFailed = "MountValidationFailed"
+	ReasonStaleWorkloadRemoved  = "StaleWorkloadRemoved"
+)
+
+const eventSubscriberBufferSize = 32
+
+// eventKey identifies a coalescing bucket of events: one per distinct
+// (Reason, Workload) pair.
+type eventKey struct {
+	reason   string
+	workload string
+}
+
+// Event is a Kubernetes-style record of something that happened to a
+// workload. Repeated events sharing the same Reason and Workload are
+// coalesced: Count is incremented and Timestamp refreshed instead of
+// appending a duplicate, so a workload failing repeatedly across monitoring
+// loops doesn't flood PopEvents with copies of the same event.
+type Event struct {
+	Type      EventType
+	Reason    string
+	Workload  string
+	Message   string
+	Timestamp time.Time
+	Count     int
+}
+
+// emitEvent records an event for workloadName, coalescing it with any
+// pending event sharing the same Reason and Workload, and fans it out to
+// every channel returned by Subscribe.
+func (w *WorkloadManager) emitEvent(workloadName string, eventType EventType, reason, message string) {
+	now := time.Now()
+	key := eventKey{reason: reason, workload: workloadName}
+
+	w.eventsLock.Lock()
+	if w.events == nil {
+		w.events = make(map[eventKey]*Event)
+	}
+	event, ok := w.events[key]
+	if ok {
+		event.Count++
+		event.Timestamp = now
+		event.Message = message
+	} else {
+		event = &Event{
+			Type:      eventType,
+			Reason:    reason,
+			Workload:  workloadName,
+			Message:   message,
+			Timestamp: now,
+			Count:     1,
+		}
+		w.events[key] = event
+		w.eventOrder = append(w.eventOrder, key)
+	}
+	eventCopy := *event
+	subscribers := append([]chan Event{}, w.eventSubscribers...)
+	w.eventsLock.Unlock()
+
+	for _, subscriber := range subscribers {
+		select {
+		case subscriber <- eventCopy:
+		default:
+			log.Warnf("event subscriber channel full, dropping event %s/%s. DeviceID: %s;", reason, workloadName, w.deviceId)
+		}
+	}
+}
+
+// PopEvents returns a copy of every pending event, ordered by first
+// occurrence, and clears the queue.
+func (w *WorkloadManager) PopEvents() []*Event {
+	w.eventsLock.Lock()
+	defer w.eventsLock.Unlock()
+
+	events := make([]*Event, 0, len(w.eventOrder))
+	for _, key := range w.eventOrder {
+		e := *w.events[key]
+		events = append(events, &e)
+	}
+	w.events = nil
+	w.eventOrder = nil
+	return events
+}
+
+// Subscribe returns a channel that receives a copy of every event as it's
+// emitted, so the heartbeat/monitoring subsystem can stream events upstream
+// in near real-time instead of polling PopEvents. The channel is buffered;
+// a slow consumer drops events rather than blocking workload reconciliation.
+func (w *WorkloadManager) Subscribe() <-chan Event {
+	ch := make(chan Event, eventSubscriberBufferSize)
+	w.eventsLock.Lock()
+	w.eventSubscribers = append(w.eventSubscribers, ch)
+	w.eventsLock.Unlock()
+	return ch
+}
@@ -0,0 +1,233 @@
+package workload
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"time"
+
+	"git.sr.ht/~spc/go-log"
+	api2 "github.com/project-flotta/flotta-device-worker/internal/workload/api"
+	v1 "k8s.io/api/core/v1"
+)
+
+// defaultProbePeriod is used when a container's LivenessProbe doesn't set
+// PeriodSeconds, matching the Kubernetes API default.
+const defaultProbePeriod = 10 * time.Second
+
+// outputSnippetLimit bounds how much of a probe's stdout/stderr we retain,
+// since this ends up embedded in the heartbeat payload.
+const outputSnippetLimit = 512
+
+// restartHealthMonitors (re)starts one healthcheck goroutine per container
+// that declares a LivenessProbe, replacing whatever was previously running
+// for this workload. For a workload with no probes configured it only stops
+// the previous monitors.
+//
+// HTTP/TCP probes dial the workload's pod sandbox IP, since workload pods
+// never run with HostNetwork. That IP is resolved fresh before every probe
+// rather than once here, because workloadIP can transiently return "" right
+// after Run and a monitor goroutine otherwise outlives that race for as long
+// as it runs.
+func (w *WorkloadManager) restartHealthMonitors(workloadName string, pod *v1.Pod) {
+	w.stopHealthMonitor(workloadName)
+
+	var probed []v1.Container
+	for _, container := range pod.Spec.Containers {
+		if container.LivenessProbe != nil {
+			probed = append(probed, container)
+		}
+	}
+	if len(probed) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	w.healthLock.Lock()
+	w.healthMonitors[workloadName] = cancel
+	w.workloadHealth[workloadName] = api2.NewWorkloadHealth()
+	w.healthLock.Unlock()
+
+	for _, container := range probed {
+		go w.runHealthMonitor(ctx, workloadName, container)
+	}
+}
+
+// workloadIP resolves workloadName's current pod sandbox IP from the
+// container runtime, for runHealthMonitor to pass to HTTP/TCP probes before
+// every probe. It returns "" if the workload can't currently be found, e.g.
+// a transient race right after Run, logging only when listing fails.
+func (w *WorkloadManager) workloadIP(workloadName string) string {
+	workloads, err := w.workloads.List()
+	if err != nil {
+		log.Errorf("cannot resolve IP address for workload %s. DeviceID: %s; err: %v", workloadName, w.deviceId, err)
+		return ""
+	}
+	for _, wl := range workloads {
+		if wl.Name == workloadName {
+			return wl.IPAddress
+		}
+	}
+	return ""
+}
+
+// stopHealthMonitor cancels any healthcheck goroutines running for
+// workloadName. Safe to call for a workload that isn't being monitored.
+func (w *WorkloadManager) stopHealthMonitor(workloadName string) {
+	w.healthLock.Lock()
+	cancel, ok := w.healthMonitors[workloadName]
+	delete(w.healthMonitors, workloadName)
+	delete(w.workloadHealth, workloadName)
+	w.healthLock.Unlock()
+
+	if ok {
+		cancel()
+	}
+}
+
+// GetWorkloadHealth returns the current healthcheck state for workloadName,
+// or false if no probes are configured or it is not currently deployed.
+func (w *WorkloadManager) GetWorkloadHealth(workloadName string) (*api2.WorkloadHealth, bool) {
+	w.healthLock.Lock()
+	defer w.healthLock.Unlock()
+	health, ok := w.workloadHealth[workloadName]
+	return health, ok
+}
+
+func (w *WorkloadManager) runHealthMonitor(ctx context.Context, workloadName string, container v1.Container) {
+	probe := container.LivenessProbe
+	period := defaultProbePeriod
+	if probe.PeriodSeconds > 0 {
+		period = time.Duration(probe.PeriodSeconds) * time.Second
+	}
+	if probe.InitialDelaySeconds > 0 {
+		select {
+		case <-time.After(time.Duration(probe.InitialDelaySeconds) * time.Second):
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		result := w.runProbe(ctx, workloadName, w.workloadIP(workloadName), container)
+
+		w.healthLock.Lock()
+		if health, ok := w.workloadHealth[workloadName]; ok {
+			health.Record(result)
+		}
+		w.healthLock.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *WorkloadManager) runProbe(ctx context.Context, workloadName, containerIP string, container v1.Container) api2.ProbeResult {
+	probe := container.LivenessProbe
+	timeout := time.Second
+	if probe.TimeoutSeconds > 0 {
+		timeout = time.Duration(probe.TimeoutSeconds) * time.Second
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var err error
+	var output string
+	switch {
+	case probe.Exec != nil:
+		output, err = w.execProbe(probeCtx, workloadName, container.Name, probe.Exec.Command)
+	case probe.HTTPGet != nil:
+		output, err = httpGetProbe(probeCtx, containerIP, probe.HTTPGet)
+	case probe.TCPSocket != nil:
+		output, err = tcpSocketProbe(probeCtx, containerIP, probe.TCPSocket)
+	default:
+		err = fmt.Errorf("unsupported probe type for container %q", container.Name)
+	}
+
+	if err != nil {
+		log.Tracef("healthcheck failed for workload %s container %s: %v", workloadName, container.Name, err)
+		return api2.ProbeResult{Time: time.Now(), Success: false, Output: truncate(err.Error())}
+	}
+	return api2.ProbeResult{Time: time.Now(), Success: true, Output: truncate(output)}
+}
+
+// execProbe runs the healthcheck command inside the container via
+// "podman exec", mirroring how Docker/Podman native healthchecks work.
+func (w *WorkloadManager) execProbe(ctx context.Context, workloadName, containerName string, command []string) (string, error) {
+	if len(command) == 0 {
+		return "", fmt.Errorf("exec probe has no command")
+	}
+	podmanContainerName := fmt.Sprintf("%s-%s", workloadName, containerName)
+	args := append([]string{"exec", podmanContainerName}, command...)
+
+	cmd := exec.CommandContext(ctx, "podman", args...) //#nosec
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return out.String(), fmt.Errorf("exec probe failed: %w", err)
+	}
+	return out.String(), nil
+}
+
+func httpGetProbe(ctx context.Context, containerIP string, action *v1.HTTPGetAction) (string, error) {
+	if containerIP == "" {
+		return "", fmt.Errorf("cannot determine workload container IP for HTTP probe")
+	}
+	scheme := "http"
+	if action.Scheme == v1.URISchemeHTTPS {
+		scheme = "https"
+	}
+	url := fmt.Sprintf("%s://%s:%d%s", scheme, containerIP, action.Port.IntValue(), action.Path)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	for _, header := range action.HTTPHeaders {
+		req.Header.Set(header.Name, header.Value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return "", fmt.Errorf("HTTP probe returned status %d", resp.StatusCode)
+	}
+	return fmt.Sprintf("HTTP %d", resp.StatusCode), nil
+}
+
+func tcpSocketProbe(ctx context.Context, containerIP string, action *v1.TCPSocketAction) (string, error) {
+	if containerIP == "" {
+		return "", fmt.Errorf("cannot determine workload container IP for TCP probe")
+	}
+	addr := fmt.Sprintf("%s:%d", containerIP, action.Port.IntValue())
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	return fmt.Sprintf("connected to %s", addr), nil
+}
+
+func truncate(s string) string {
+	if len(s) <= outputSnippetLimit {
+		return s
+	}
+	return s[:outputSnippetLimit]
+}
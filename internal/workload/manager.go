@@ -28,6 +28,8 @@ const (
 
 	AuthFileName     = "auth.json"
 	WorkloadFileName = "workload.yaml"
+
+	stagedDirName = ".staged"
 )
 
 type WorkloadManager struct {
@@ -36,8 +38,20 @@ type WorkloadManager struct {
 	workloads      WorkloadWrapper
 	managementLock sync.Locker
 	deregistered   bool
-	eventsQueue    []*models.EventInfo
 	deviceId       string
+
+	eventsLock       sync.Mutex
+	events           map[eventKey]*Event
+	eventOrder       []eventKey
+	eventSubscribers []chan Event
+
+	healthLock     sync.Mutex
+	healthMonitors map[string]context.CancelFunc
+	workloadHealth map[string]*api2.WorkloadHealth
+
+	restartOnSecretRotation bool
+	secretHashesPath        string
+	secretHashes            map[string]string
 }
 
 func NewWorkloadManager(dataDir string, deviceId string, systemdEventCh <-chan *service.Event) (*WorkloadManager, error) {
@@ -49,11 +63,15 @@ func NewWorkloadManager(dataDir string, deviceId string, systemdEventCh <-chan *
 	return NewWorkloadManagerWithParams(dataDir, wrapper, deviceId)
 }
 
-func NewWorkloadManagerWithParams(dataDir string, ww WorkloadWrapper, deviceId string) (*WorkloadManager, error) {
-	return NewWorkloadManagerWithParamsAndInterval(dataDir, ww, defaultWorkloadsMonitoringInterval, deviceId)
+// NewWorkloadManagerWithParams creates a WorkloadManager. restartOnSecretRotation
+// is optional and defaults to true: when true, a workload referencing a
+// secret whose content changed is force-restarted on Update even if the
+// workload's own spec is unchanged.
+func NewWorkloadManagerWithParams(dataDir string, ww WorkloadWrapper, deviceId string, restartOnSecretRotation ...bool) (*WorkloadManager, error) {
+	return NewWorkloadManagerWithParamsAndInterval(dataDir, ww, defaultWorkloadsMonitoringInterval, deviceId, restartOnSecretRotation...)
 }
 
-func NewWorkloadManagerWithParamsAndInterval(dataDir string, ww WorkloadWrapper, monitorInterval uint, deviceId string) (*WorkloadManager, error) {
+func NewWorkloadManagerWithParamsAndInterval(dataDir string, ww WorkloadWrapper, monitorInterval uint, deviceId string, restartOnSecretRotation ...bool) (*WorkloadManager, error) {
 	workloadsDir := path.Join(dataDir, "workloads")
 	/* #nosec */
 	if err := os.MkdirAll(workloadsDir, 0777); err != nil {
@@ -66,13 +84,18 @@ func NewWorkloadManagerWithParamsAndInterval(dataDir string, ww WorkloadWrapper,
 		return nil, fmt.Errorf("cannot create directory: %w", err)
 	}
 	manager := WorkloadManager{
-		workloadsDir:   workloadsDir,
-		volumesDir:     volumesDir,
-		workloads:      ww,
-		managementLock: &sync.Mutex{},
-		deregistered:   false,
-		deviceId:       deviceId,
-	}
+		workloadsDir:            workloadsDir,
+		volumesDir:              volumesDir,
+		workloads:               ww,
+		managementLock:          &sync.Mutex{},
+		deregistered:            false,
+		deviceId:                deviceId,
+		healthMonitors:          make(map[string]context.CancelFunc),
+		workloadHealth:          make(map[string]*api2.WorkloadHealth),
+		restartOnSecretRotation: len(restartOnSecretRotation) == 0 || restartOnSecretRotation[0],
+		secretHashesPath:        secretHashesPath(dataDir),
+	}
+	manager.secretHashes = manager.loadSecretHashes()
 	if err := manager.workloads.Init(); err != nil {
 		return nil, err
 	}
@@ -84,22 +107,6 @@ func (w *WorkloadManager) String() string {
 	return "workload manager"
 }
 
-// PopEvents return copy of all the events stored in eventQueue
-func (w *WorkloadManager) PopEvents() []*models.EventInfo {
-	w.managementLock.Lock()
-	defer w.managementLock.Unlock()
-
-	// Copy the events:
-	events := []*models.EventInfo{}
-	for _, event := range w.eventsQueue {
-		e := *event
-		events = append(events, &e)
-	}
-	// Empty the events:
-	w.eventsQueue = []*models.EventInfo{}
-	return events
-}
-
 func (w *WorkloadManager) ListWorkloads() ([]api2.WorkloadInfo, error) {
 	return w.workloads.List()
 }
@@ -125,12 +132,24 @@ func (w *WorkloadManager) Update(configuration models.DeviceConfigurationMessage
 		return errors
 	}
 
+	changedSecrets := w.reconcileSecretHashes(configuration.Secrets)
+
 	errs := w.updateSecrets(configuration.Secrets)
 	if len(errs) != 0 {
 		errors = multierror.Append(errors, errs...)
 	}
 
+	deployedWorkloadByName, err := w.indexWorkloads()
+	if err != nil {
+		log.Errorf("cannot get deployed workloads. DeviceID: %s; err: %v", w.deviceId, err)
+		errors = multierror.Append(errors, fmt.Errorf("cannot get deployed workloads: %s", err))
+		return errors
+	}
+
+	forceReconcile := configuration.Configuration != nil && configuration.Configuration.ForceReconcile
+
 	configuredWorkloadNameSet := make(map[string]struct{})
+	reconciledWorkloadNameSet := make(map[string]struct{})
 	for _, workload := range configuration.Workloads {
 		log.Tracef("deploying workload: %s. DeviceID: %s;", workload.Name, w.deviceId)
 		configuredWorkloadNameSet[workload.Name] = struct{}{}
@@ -148,6 +167,8 @@ func (w *WorkloadManager) Update(configuration models.DeviceConfigurationMessage
 		if PodShouldWaitForMount(pod, configuration.Configuration) {
 			errors = multierror.Append(errors, fmt.Errorf(
 				"pod '%s' needs to mount blockdevice but it's not in there yet", workload.Name))
+			w.emitEvent(workload.Name, EventTypeWarning, ReasonMountValidationFailed,
+				fmt.Sprintf("pod '%s' needs to mount blockdevice but it's not in there yet", workload.Name))
 			continue
 		}
 
@@ -170,21 +191,23 @@ func (w *WorkloadManager) Update(configuration models.DeviceConfigurationMessage
 
 		manifestPath := w.getManifestPath(pod.Name)
 		authFilePath := w.getAuthFilePath(pod.Name)
-		if !w.podConfigurationModified(manifestPath, podYaml, authFilePath, authFile) {
+
+		reconcileHash := w.computeReconcileHash(podYaml, authFile, pod)
+		storedHash, hashErr := w.loadReconcileHash(workload.Name)
+		deployed, isDeployed := deployedWorkloadByName[workload.Name]
+		runtimeRunning := isDeployed && strings.EqualFold(deployed.Status, "running")
+		if !forceReconcile && hashErr == nil && storedHash == reconcileHash && runtimeRunning {
 			log.Tracef("pod '%s' definition is unchanged (%s). DeviceID: %s;", workload.Name, manifestPath, w.deviceId)
 			continue
 		}
-		err = w.storeFile(manifestPath, podYaml)
-		if err != nil {
-			errors = multierror.Append(errors, fmt.Errorf(
-				"cannot store manifest for workload '%s': %s", workload.Name, err))
-			continue
-		}
 
-		authFilePath, err = w.manageAuthFile(authFilePath, authFile)
-		if err != nil {
+		snapshot := w.snapshotWorkloadFiles(manifestPath, authFilePath)
+
+		if err := w.stageAndSwapWorkloadFiles(workload.Name, manifestPath, podYaml, authFilePath, authFile); err != nil {
 			errors = multierror.Append(errors, fmt.Errorf(
-				"cannot store auth configuration for workload '%s': %s", workload.Name, err))
+				"cannot store manifest for workload '%s': %s", workload.Name, err))
+			w.emitEvent(workload.Name, EventTypeWarning, ReasonManifestWriteFailed,
+				fmt.Sprintf("cannot store manifest for workload '%s': %s", workload.Name, err))
 			continue
 		}
 
@@ -195,37 +218,52 @@ func (w *WorkloadManager) Update(configuration models.DeviceConfigurationMessage
 			continue
 		}
 
+		runAuthFilePath := authFilePath
+		if authFile == "" {
+			runAuthFilePath = ""
+		}
+
 		// TODO: extract podman specific annotations from the workload.
-		err = w.workloads.Run(pod, manifestPath, authFilePath, workload.Annotations)
+		err = w.workloads.Run(pod, manifestPath, runAuthFilePath, workload.Annotations)
 		if err != nil {
 			log.Errorf("cannot run workload. DeviceID: %s; err: %v", w.deviceId, err)
-			w.eventsQueue = append(w.eventsQueue, &models.EventInfo{
-				Message: err.Error(),
-				Reason:  "Failed",
-				Type:    models.EventInfoTypeWarn,
-			})
-
 			errors = multierror.Append(errors, fmt.Errorf(
 				"cannot run workload '%s': %s", workload.Name, err))
+
+			if snapshot.existed && w.rollbackWorkload(workload.Name, manifestPath, authFilePath, snapshot) {
+				w.emitEvent(workload.Name, EventTypeWarning, ReasonWorkloadRolledBack, fmt.Sprintf(
+					"rolled back workload '%s' to its previous definition after a failed update: %s", workload.Name, err))
+			} else {
+				w.emitEvent(workload.Name, EventTypeWarning, ReasonWorkloadStartFailed, err.Error())
+			}
 			continue
 		}
+
+		if err := w.storeReconcileHash(workload.Name, reconcileHash); err != nil {
+			log.Errorf("cannot persist reconcile hash for workload %s. DeviceID: %s; err: %v", workload.Name, w.deviceId, err)
+		}
+
+		w.restartHealthMonitors(workload.Name, pod)
+		reconciledWorkloadNameSet[workload.Name] = struct{}{}
 	}
 
-	deployedWorkloadByName, err := w.indexWorkloads()
-	if err != nil {
-		log.Errorf("cannot get deployed workloads. DeviceID: %s; err: %v", w.deviceId, err)
-		errors = multierror.Append(errors, fmt.Errorf("cannot get deployed workloads: %s", err))
-		return errors
+	if w.restartOnSecretRotation && len(changedSecrets) > 0 {
+		w.restartWorkloadsForSecretRotation(configuration.Workloads, changedSecrets, reconciledWorkloadNameSet)
 	}
+
 	// Remove any workloads that don't correspond to the configured ones
 	for name := range deployedWorkloadByName {
 		if _, ok := configuredWorkloadNameSet[name]; !ok {
 			log.Infof("workload not found: %s. Removing. DeviceID: %s;", name, w.deviceId)
+			w.stopHealthMonitor(name)
 			if err := deleteDir(w.getWorkloadDirPath(name)); err != nil {
 				errors = multierror.Append(errors, fmt.Errorf("cannot remove existing workload directory: %s", err))
 			}
 			if err := w.workloads.Remove(name); err != nil {
 				errors = multierror.Append(errors, fmt.Errorf("cannot remove stale workload name='%s': %s", name, err))
+			} else {
+				w.emitEvent(name, EventTypeNormal, ReasonStaleWorkloadRemoved,
+					fmt.Sprintf("removed workload '%s' because it's no longer in the device configuration", name))
 			}
 			log.Infof("workload %s removed. DeviceID: %s;", name, w.deviceId)
 		}
@@ -249,20 +287,165 @@ func (w *WorkloadManager) ensureWorkloadDirExists(workloadName string) error {
 	return nil
 }
 
-// manageAuthFile is responsible for bringing auth configuration file under authFilePath to expected state;
-// if the content of the file - authFile is supposed to be blank, the file is removed, otherwise authFile is written
-// to the authFilePath file.
-func (w *WorkloadManager) manageAuthFile(authFilePath, authFile string) (string, error) {
+// workloadSnapshot captures a workload's previously deployed manifest/auth
+// file contents so a failed update can be rolled back to them.
+type workloadSnapshot struct {
+	existed       bool
+	manifestBytes []byte
+	authExisted   bool
+	authBytes     []byte
+}
+
+func (w *WorkloadManager) snapshotWorkloadFiles(manifestPath, authFilePath string) workloadSnapshot {
+	var snapshot workloadSnapshot
+	if data, err := ioutil.ReadFile(manifestPath); err == nil { //#nosec
+		snapshot.existed = true
+		snapshot.manifestBytes = data
+	}
+	if data, err := ioutil.ReadFile(authFilePath); err == nil { //#nosec
+		snapshot.authExisted = true
+		snapshot.authBytes = data
+	}
+	return snapshot
+}
+
+// stageAndSwapWorkloadFiles writes the new manifest (and, if set, auth file)
+// to a staging directory under the workload directory first, so that a
+// failed write never leaves a partially-written manifest live, then swaps
+// the staged files into place.
+func (w *WorkloadManager) stageAndSwapWorkloadFiles(workloadName, manifestPath string, podYaml []byte, authFilePath, authFile string) error {
+	stagedDir := path.Join(w.getWorkloadDirPath(workloadName), stagedDirName)
+	/* #nosec */
+	if err := os.MkdirAll(stagedDir, 0755); err != nil {
+		return fmt.Errorf("cannot create staging directory: %w", err)
+	}
+	defer deleteDir(stagedDir)
+
+	stagedManifest := path.Join(stagedDir, WorkloadFileName)
+	if err := w.storeFile(stagedManifest, podYaml); err != nil {
+		return fmt.Errorf("cannot stage manifest: %w", err)
+	}
+	if err := os.Rename(stagedManifest, manifestPath); err != nil {
+		return fmt.Errorf("cannot swap manifest into place: %w", err)
+	}
+
 	if authFile == "" {
 		if err := deleteFile(authFilePath); err != nil {
-			return "", fmt.Errorf("cannot remove auth file %s: %s", authFilePath, err)
+			return fmt.Errorf("cannot remove auth file %s: %w", authFilePath, err)
 		}
-		return "", nil
+		return nil
 	}
-	if err := w.storeFile(authFilePath, []byte(authFile)); err != nil {
-		return "", fmt.Errorf("cannot store auth file %s: %s", authFilePath, err)
+
+	stagedAuth := path.Join(stagedDir, AuthFileName)
+	if err := w.storeFile(stagedAuth, []byte(authFile)); err != nil {
+		return fmt.Errorf("cannot stage auth file: %w", err)
 	}
-	return authFilePath, nil
+	return os.Rename(stagedAuth, authFilePath)
+}
+
+// rollbackWorkload restores a workload's previous manifest/auth files from
+// snapshot and re-runs the previous pod, giving config-driven deploys the
+// same safety net as podman auto-update's own rollback behavior. It returns
+// whether the rollback itself succeeded.
+func (w *WorkloadManager) rollbackWorkload(workloadName, manifestPath, authFilePath string, snapshot workloadSnapshot) bool {
+	if err := w.storeFile(manifestPath, snapshot.manifestBytes); err != nil {
+		log.Errorf("cannot restore previous manifest for workload %s: %v", workloadName, err)
+		return false
+	}
+
+	restoredAuthFilePath := ""
+	if snapshot.authExisted {
+		if err := w.storeFile(authFilePath, snapshot.authBytes); err != nil {
+			log.Errorf("cannot restore previous auth file for workload %s: %v", workloadName, err)
+			return false
+		}
+		restoredAuthFilePath = authFilePath
+	} else if err := deleteFile(authFilePath); err != nil {
+		log.Errorf("cannot remove auth file for workload %s: %v", workloadName, err)
+		return false
+	}
+
+	oldPod := v1.Pod{}
+	if err := yaml.Unmarshal(manifestDocument(snapshot.manifestBytes), &oldPod); err != nil {
+		log.Errorf("cannot parse previous manifest for workload %s: %v", workloadName, err)
+		return false
+	}
+
+	if err := w.workloads.Remove(workloadName); err != nil {
+		log.Errorf("cannot remove failed workload %s during rollback: %v", workloadName, err)
+	}
+	if err := w.workloads.Run(&oldPod, manifestPath, restoredAuthFilePath, oldPod.Annotations); err != nil {
+		log.Errorf("cannot re-run previous workload %s during rollback: %v", workloadName, err)
+		return false
+	}
+	return true
+}
+
+// manifestDocument strips the configmap documents that toPodYaml appends
+// after the pod's own YAML, since only the leading document unmarshals into
+// a v1.Pod.
+func manifestDocument(manifest []byte) []byte {
+	if idx := bytes.Index(manifest, []byte("---\n")); idx >= 0 {
+		return manifest[:idx]
+	}
+	return manifest
+}
+
+// UpdateAllOrNothing behaves like Update, but treats the whole batch of
+// workload changes as a single transaction: if Update reports any failure,
+// every workload that was part of this configuration batch is rolled back to
+// its pre-Update definition instead of leaving a partially-applied batch.
+// Workloads not present in configuration are left untouched - an unrelated
+// healthy workload that happens to already be deployed must not be
+// force-restarted because a different workload in the same batch failed.
+func (w *WorkloadManager) UpdateAllOrNothing(configuration models.DeviceConfigurationMessage) error {
+	w.managementLock.Lock()
+	snapshots := w.snapshotWorkloads(configuration.Workloads)
+	w.managementLock.Unlock()
+
+	err := w.Update(configuration)
+	if err == nil {
+		return nil
+	}
+
+	w.managementLock.Lock()
+	defer w.managementLock.Unlock()
+	for name, snapshot := range snapshots {
+		if !snapshot.existed {
+			// This workload was newly added by the failed batch, so there's
+			// no previous definition to roll back to - remove it instead.
+			if removeErr := w.workloads.Remove(name); removeErr != nil {
+				log.Errorf("cannot remove new workload %s during all-or-nothing rollback: %v", name, removeErr)
+				continue
+			}
+			if removeErr := deleteDir(w.getWorkloadDirPath(name)); removeErr != nil {
+				log.Errorf("cannot remove workload directory for %s during all-or-nothing rollback: %v", name, removeErr)
+			}
+			w.emitEvent(name, EventTypeWarning, ReasonWorkloadRolledBack, fmt.Sprintf(
+				"removed newly added workload '%s' as part of an all-or-nothing batch update that failed: %s", name, err))
+			continue
+		}
+
+		manifestPath := w.getManifestPath(name)
+		authFilePath := w.getAuthFilePath(name)
+		if w.rollbackWorkload(name, manifestPath, authFilePath, snapshot) {
+			w.emitEvent(name, EventTypeWarning, ReasonWorkloadRolledBack, fmt.Sprintf(
+				"rolled back workload '%s' as part of an all-or-nothing batch update that failed: %s", name, err))
+		}
+	}
+	return err
+}
+
+// snapshotWorkloads captures the pre-Update manifest/auth files of exactly
+// the workloads present in workloads, so UpdateAllOrNothing's rollback is
+// scoped to the batch being applied rather than every workload currently
+// deployed on the device.
+func (w *WorkloadManager) snapshotWorkloads(workloads []*models.Workload) map[string]workloadSnapshot {
+	snapshots := make(map[string]workloadSnapshot)
+	for _, workload := range workloads {
+		snapshots[workload.Name] = w.snapshotWorkloadFiles(w.getManifestPath(workload.Name), w.getAuthFilePath(workload.Name))
+	}
+	return snapshots
 }
 
 func (w *WorkloadManager) storeFile(filePath string, content []byte) error {
@@ -367,6 +550,7 @@ func (w *WorkloadManager) removeAllWorkloads() error {
 	var res error
 	for _, workload := range workloads {
 		log.Infof("removing workload %s.  DeviceID: %s;", workload.Name, w.deviceId)
+		w.stopHealthMonitor(workload.Name)
 		err := w.workloads.Remove(workload.Name)
 		if err != nil {
 			log.Errorf("error removing workload %s. DeviceID: %s; err: %v", workload.Name, w.deviceId, err)
@@ -381,15 +565,26 @@ func (w *WorkloadManager) stopAllWorkloads() error {
 	if err != nil {
 		return err
 	}
+
 	var res error
+	var resLock sync.Mutex
+	var wg sync.WaitGroup
 	for _, workload := range workloads {
-		log.Infof("stopping workload %s. DeviceID: %s;", workload.Name, w.deviceId)
-		err := w.workloads.Stop(workload.Name)
-		if err != nil {
-			log.Errorf("error stopping workload %s. DeviceID: %s; err: %v", workload.Name, w.deviceId, err)
-			res = multierror.Append(res, err)
-		}
+		wg.Add(1)
+		go func(workload api2.WorkloadInfo) {
+			defer wg.Done()
+			log.Infof("stopping workload %s. DeviceID: %s;", workload.Name, w.deviceId)
+			w.stopHealthMonitor(workload.Name)
+			if err := w.workloads.Stop(workload.Name); err != nil {
+				log.Errorf("error stopping workload %s. DeviceID: %s; err: %v", workload.Name, w.deviceId, err)
+				resLock.Lock()
+				res = multierror.Append(res, err)
+				resLock.Unlock()
+			}
+		}(workload)
 	}
+	wg.Wait()
+
 	return res
 }
 
@@ -460,6 +655,14 @@ func (w *WorkloadManager) toPod(workload *models.Workload) (*v1.Pod, error) {
 		}
 		container.VolumeMounts = append(container.VolumeMounts, mount)
 		container.Env = append(container.Env, v1.EnvVar{Name: "DEVICE_ID", Value: w.deviceId})
+
+		resolvedMounts, subPathVolumes, err := resolveSubPathMounts(container, pod.Spec.Volumes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve subPath for container '%s': %w", container.Name, err)
+		}
+		container.VolumeMounts = resolvedMounts
+		pod.Spec.Volumes = append(pod.Spec.Volumes, subPathVolumes...)
+
 		containers = append(containers, container)
 	}
 	pod.Spec.Containers = containers
@@ -467,6 +670,20 @@ func (w *WorkloadManager) toPod(workload *models.Workload) (*v1.Pod, error) {
 		pod.Labels = map[string]string{}
 	}
 
+	// A Schedule turns the workload into a one-shot, timer-triggered run
+	// instead of a long-running daemon: the generated systemd unit invokes
+	// "podman play kube"/"podman kube down" once per timer tick rather than
+	// keeping the pod up, so the pod itself must not be restarted by podman.
+	if workload.Schedule != nil {
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations["workload.flotta.io/schedule-on-calendar"] = workload.Schedule.OnCalendar
+		pod.Annotations["workload.flotta.io/schedule-on-boot-sec"] = workload.Schedule.OnBootSec
+		pod.Annotations["workload.flotta.io/schedule-on-unit-active-sec"] = workload.Schedule.OnUnitActiveSec
+		pod.Spec.RestartPolicy = v1.RestartPolicyNever
+	}
+
 	// Set the authfile label to pod, if ImageRegistry authfile is set:
 	if workload.ImageRegistries != nil && workload.ImageRegistries.AuthFile != "" {
 		pod.Labels["io.containers.autoupdate.authfile"] = w.getAuthFilePath(workload.Name)
@@ -485,6 +702,65 @@ func (w *WorkloadManager) toPod(workload *models.Workload) (*v1.Pod, error) {
 	return &pod, nil
 }
 
+// resolveSubPathMounts resolves Kubernetes-style subPath/subPathExpr on
+// container's VolumeMounts against the host directory backing each
+// referenced volume. Since "podman play kube" has no notion of subPath, a
+// mount using it is given its own volume pointing directly at the resolved
+// (and pre-created) host subdirectory, so each container gets an isolated
+// subtree of a shared host-path volume.
+func resolveSubPathMounts(container v1.Container, podVolumes []v1.Volume) ([]v1.VolumeMount, []v1.Volume, error) {
+	volumesByName := make(map[string]v1.Volume, len(podVolumes))
+	for _, vol := range podVolumes {
+		volumesByName[vol.Name] = vol
+	}
+
+	ownerUID, ownerGID := -1, -1
+	if container.SecurityContext != nil {
+		if container.SecurityContext.RunAsUser != nil {
+			ownerUID = int(*container.SecurityContext.RunAsUser)
+		}
+		if container.SecurityContext.RunAsGroup != nil {
+			ownerGID = int(*container.SecurityContext.RunAsGroup)
+		}
+	}
+
+	var subPathVolumes []v1.Volume
+	mounts := make([]v1.VolumeMount, 0, len(container.VolumeMounts))
+	for i, mount := range container.VolumeMounts {
+		if mount.SubPath == "" && mount.SubPathExpr == "" {
+			mounts = append(mounts, mount)
+			continue
+		}
+
+		vol, ok := volumesByName[mount.Name]
+		if !ok || vol.HostPath == nil {
+			return nil, nil, fmt.Errorf("volume '%s' for mount '%s' must be a host-backed volume to use subPath", mount.Name, mount.MountPath)
+		}
+
+		resolvedPath, err := volumes.ResolveMountSubPath(vol.HostPath.Path, mount, container.Env, ownerUID, ownerGID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		subVolume := *vol.DeepCopy()
+		// Container names are unique within a pod, but a mount's index
+		// within its own container's VolumeMounts isn't - two containers
+		// each subPath-mounting the same volume at the same local index
+		// (e.g. a main + sidecar sharing a workdir) would otherwise collide
+		// on the same generated volume name despite resolving to different
+		// host paths.
+		subVolume.Name = fmt.Sprintf("%s-%s-subpath-%d", vol.Name, container.Name, i)
+		subVolume.HostPath.Path = resolvedPath
+		subPathVolumes = append(subPathVolumes, subVolume)
+
+		mount.Name = subVolume.Name
+		mount.SubPath = ""
+		mount.SubPathExpr = ""
+		mounts = append(mounts, mount)
+	}
+	return mounts, subPathVolumes, nil
+}
+
 func PodShouldWaitForMount(pod *v1.Pod, deviceConf *models.DeviceConfiguration) bool {
 	if deviceConf == nil || len(deviceConf.Mounts) == 0 {
 		return false
@@ -521,29 +797,6 @@ func PodShouldWaitForMount(pod *v1.Pod, deviceConf *models.DeviceConfiguration)
 	return false
 }
 
-func (w *WorkloadManager) podConfigurationModified(manifestPath string, podYaml []byte, authPath string, auth string) bool {
-	return w.podModified(manifestPath, podYaml) || w.podAuthModified(authPath, auth)
-}
-
-func (w *WorkloadManager) podModified(manifestPath string, podYaml []byte) bool {
-	file, err := ioutil.ReadFile(manifestPath) //#nosec
-	if err != nil {
-		return true
-	}
-	return !bytes.Equal(file, podYaml)
-}
-
-func (w *WorkloadManager) podAuthModified(authPath string, auth string) bool {
-	if _, err := os.Stat(authPath); err != nil {
-		return auth != ""
-	}
-	file, err := ioutil.ReadFile(authPath) //#nosec
-	if err != nil {
-		return true
-	}
-	return !bytes.Equal(file, []byte(auth))
-}
-
 func (w *WorkloadManager) updateSecrets(configSecrets models.SecretList) []error {
 	deviceSecrets, err := w.workloads.ListSecrets()
 	if err != nil {
@@ -559,12 +812,14 @@ func (w *WorkloadManager) updateSecrets(configSecrets models.SecretList) []error
 		}
 		if err != nil {
 			errs = append(errs, err)
+			w.emitEvent(configSecret.Name, EventTypeWarning, ReasonSecretSyncFailed, err.Error())
 		}
 	}
 	for deviceName := range deviceSecrets {
 		err = w.workloads.RemoveSecret(deviceName)
 		if err != nil {
 			errs = append(errs, err)
+			w.emitEvent(deviceName, EventTypeWarning, ReasonSecretSyncFailed, err.Error())
 		}
 	}
 	return errs
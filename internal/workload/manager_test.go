@@ -100,10 +100,12 @@ var _ = Describe("Events", func() {
 			// then
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("Failed to start container"))
-			// Check no events are generated:
+			// Check the failure was recorded as a typed event:
 			time.Sleep(5 * time.Second)
 			events := wkManager.PopEvents()
 			Expect(len(events)).To(BeNumerically(">=", 1))
+			Expect(events[0].Reason).To(Equal(workload.ReasonWorkloadStartFailed))
+			Expect(events[0].Workload).To(Equal("stale"))
 		})
 	})
 
@@ -578,6 +580,80 @@ volumes:
 		})
 	})
 
+	Context("Content-hash reconciliation", func() {
+		var cfg models.DeviceConfigurationMessage
+
+		BeforeEach(func() {
+			cfg = models.DeviceConfigurationMessage{
+				Configuration: &models.DeviceConfiguration{Heartbeat: &models.HeartbeatConfiguration{PeriodSeconds: 1}},
+				Workloads: []*models.Workload{
+					{
+						Data:          &models.DataConfiguration{},
+						Name:          "test",
+						Specification: podSpec,
+					},
+				},
+			}
+			wkwMock.EXPECT().ListSecrets().Return(nil, nil).AnyTimes()
+		})
+
+		It("issues zero Run/Remove calls on a no-op update", func() {
+			// given: deploy once so a hash is stored and the runtime reports running
+			wkwMock.EXPECT().List().Return(nil, nil).Times(1)
+			wkwMock.EXPECT().Remove("test").Times(1)
+			wkwMock.EXPECT().Run(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(1)
+			Expect(wkManager.Update(cfg)).NotTo(HaveOccurred())
+
+			// when: the exact same configuration is applied again, and the
+			// runtime now reports the workload as running
+			wkwMock.EXPECT().List().Return([]api.WorkloadInfo{
+				{Id: "test", Name: "test", Status: "running"},
+			}, nil).Times(1)
+
+			// then: no further Remove/Run is issued (the mock would fail the
+			// test if either were called, since no further expectation exists)
+			Expect(wkManager.Update(cfg)).NotTo(HaveOccurred())
+		})
+
+		It("restarts the workload when its spec changes", func() {
+			// given
+			wkwMock.EXPECT().List().Return(nil, nil).Times(1)
+			wkwMock.EXPECT().Remove("test").Times(1)
+			wkwMock.EXPECT().Run(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(1)
+			Expect(wkManager.Update(cfg)).NotTo(HaveOccurred())
+
+			// when: the workload's specification changes
+			cfg.Workloads[0].Specification = podSpec + "\n      imagePullPolicy: Always"
+			wkwMock.EXPECT().List().Return([]api.WorkloadInfo{
+				{Id: "test", Name: "test", Status: "running"},
+			}, nil).Times(1)
+			wkwMock.EXPECT().Remove("test").Times(1)
+			wkwMock.EXPECT().Run(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+			// then
+			Expect(wkManager.Update(cfg)).NotTo(HaveOccurred())
+		})
+
+		It("restarts the workload when the runtime state has drifted from running", func() {
+			// given
+			wkwMock.EXPECT().List().Return(nil, nil).Times(1)
+			wkwMock.EXPECT().Remove("test").Times(1)
+			wkwMock.EXPECT().Run(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(1)
+			Expect(wkManager.Update(cfg)).NotTo(HaveOccurred())
+
+			// when: the same configuration is applied again, but the runtime
+			// no longer reports the workload as running
+			wkwMock.EXPECT().List().Return([]api.WorkloadInfo{
+				{Id: "test", Name: "test", Status: "exited"},
+			}, nil).Times(1)
+			wkwMock.EXPECT().Remove("test").Times(1)
+			wkwMock.EXPECT().Run(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+			// then
+			Expect(wkManager.Update(cfg)).NotTo(HaveOccurred())
+		})
+	})
+
 	Context("ListWorkloads", func() {
 		It("Return the list correctly", func() {
 
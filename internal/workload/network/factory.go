@@ -0,0 +1,34 @@
+package network
+
+import (
+	"os"
+	"os/exec"
+)
+
+const ipTablesNamesPath = "/proc/net/ip_tables_names"
+
+// NewDefaultNetfilter autodetects which firewall backend this host actually
+// uses and returns the matching Netfilter implementation: nftables is
+// preferred when it's available and no legacy iptables tables are loaded,
+// since mixing both backends on the same host risks corrupting rule
+// ordering.
+func NewDefaultNetfilter() (Netfilter, error) {
+	if !iptablesLoaded() && nftablesAvailable() {
+		return NewNFTables()
+	}
+	return NewIPTables(), nil
+}
+
+// iptablesLoaded reports whether the legacy iptables (not iptables-nft)
+// kernel module is loaded, i.e. whether ipTablesNamesPath exists; the file's
+// contents (which tables are registered) are not read.
+func iptablesLoaded() bool {
+	_, err := os.Stat(ipTablesNamesPath)
+	return err == nil
+}
+
+// nftablesAvailable reports whether the nft command-line tool can talk to
+// the kernel's nftables subsystem at all.
+func nftablesAvailable() bool {
+	return exec.Command("nft", "list", "ruleset").Run() == nil //#nosec
+}
@@ -0,0 +1,105 @@
+package network
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+// IPSetReconciler diff-applies the desired membership of every ipset named
+// in a device's network configuration, so re-applying an unchanged
+// configuration costs nothing beyond the diff, and a shrinking peer list
+// doesn't leave stale entries (and therefore stale allowed peers) behind.
+type IPSetReconciler struct {
+	nf Netfilter
+
+	lock    sync.Mutex
+	applied map[string]map[string]struct{}
+}
+
+// NewIPSetReconciler builds an IPSetReconciler that manages sets through nf.
+func NewIPSetReconciler(nf Netfilter) *IPSetReconciler {
+	return &IPSetReconciler{
+		nf:      nf,
+		applied: make(map[string]map[string]struct{}),
+	}
+}
+
+// Reconcile brings every set in config's IPSets in line with its desired
+// entries, creating sets that don't exist yet and diffing membership against
+// what this reconciler last applied, rather than destroying and recreating
+// the set on every call.
+func (r *IPSetReconciler) Reconcile(config *models.NetworkConfiguration) error {
+	if config == nil {
+		return nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var errs []string
+	seen := make(map[string]struct{}, len(config.IPSets))
+	for _, ipSet := range config.IPSets {
+		seen[ipSet.Name] = struct{}{}
+		if err := r.reconcileSet(ipSet.Name, ipSet.Type, ipSet.Entries); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	for name := range r.applied {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		if err := r.nf.DestroySet(name); err != nil {
+			errs = append(errs, fmt.Sprintf("cannot destroy stale ipset %s: %v", name, err))
+			continue
+		}
+		delete(r.applied, name)
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("cannot reconcile ipsets: %s", errs)
+	}
+	return nil
+}
+
+func (r *IPSetReconciler) reconcileSet(name, setType string, desired []string) error {
+	if _, exists := r.applied[name]; !exists {
+		if err := r.nf.CreateSet(name, setType); err != nil {
+			return fmt.Errorf("cannot create ipset %s: %w", name, err)
+		}
+	}
+
+	desiredSet := make(map[string]struct{}, len(desired))
+	for _, entry := range desired {
+		desiredSet[entry] = struct{}{}
+	}
+
+	current := r.applied[name]
+	for entry := range current {
+		if _, ok := desiredSet[entry]; ok {
+			continue
+		}
+		if err := r.nf.DeleteEntry(name, entry); err != nil {
+			return fmt.Errorf("cannot remove stale entry %s from ipset %s: %w", entry, name, err)
+		}
+		delete(current, entry)
+	}
+
+	if current == nil {
+		current = make(map[string]struct{}, len(desired))
+		r.applied[name] = current
+	}
+	for entry := range desiredSet {
+		if _, ok := current[entry]; ok {
+			continue
+		}
+		if err := r.nf.AddEntry(name, entry); err != nil {
+			return fmt.Errorf("cannot add entry %s to ipset %s: %w", entry, name, err)
+		}
+		current[entry] = struct{}{}
+	}
+
+	return nil
+}
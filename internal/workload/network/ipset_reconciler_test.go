@@ -0,0 +1,29 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/project-flotta/flotta-operator/models"
+)
+
+func TestReconcileOnlyCreatesASetOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	nf := NewMockNetfilter(ctrl)
+	nf.EXPECT().CreateSet("allow", "hash:net").Return(nil).Times(1)
+	nf.EXPECT().AddEntry("allow", "10.0.0.1").Return(nil).Times(1)
+
+	r := NewIPSetReconciler(nf)
+	config := &models.NetworkConfiguration{
+		IPSets: []*models.IPSet{{Name: "allow", Type: "hash:net", Entries: []string{"10.0.0.1"}}},
+	}
+
+	if err := r.Reconcile(config); err != nil {
+		t.Fatalf("unexpected error on first reconcile: %v", err)
+	}
+	if err := r.Reconcile(config); err != nil {
+		t.Fatalf("unexpected error on second reconcile: %v", err)
+	}
+}
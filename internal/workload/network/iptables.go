@@ -0,0 +1,97 @@
+package network
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// IPTables is a Netfilter implementation that shells out to the legacy
+// iptables and ipset command-line tools. It's the portable fallback for
+// hosts where nftables isn't available or enabled.
+type IPTables struct{}
+
+// NewIPTables builds an IPTables backend.
+func NewIPTables() *IPTables {
+	return &IPTables{}
+}
+
+func (*IPTables) Backend() Backend {
+	return BackendIPTables
+}
+
+func (*IPTables) AddTable(name string) error {
+	// iptables has no user-creatable tables beyond the built-in filter/nat/
+	// mangle/raw ones, so creating "name" is a no-op: the table already
+	// exists if the kernel module for it is loaded.
+	return nil
+}
+
+func (*IPTables) DeleteTable(name string) error {
+	return nil
+}
+
+func (*IPTables) AddChain(table, chain string) error {
+	return runIPTables("-t", table, "-N", chain)
+}
+
+func (*IPTables) DeleteChain(table, chain string) error {
+	return runIPTables("-t", table, "-X", chain)
+}
+
+func (*IPTables) AddRule(table, chain, spec string) error {
+	args := append([]string{"-t", table, "-A", chain}, strings.Fields(spec)...)
+	return runIPTables(args...)
+}
+
+func (*IPTables) AddRuleWithSet(table, chain, spec string, sets []string) error {
+	args := append([]string{"-t", table, "-A", chain}, strings.Fields(spec)...)
+	for _, set := range sets {
+		args = append(args, "-m", "set", "--match-set", set, "src")
+	}
+	return runIPTables(args...)
+}
+
+func (*IPTables) CreateSet(name, setType string) error {
+	return runIPSet("create", name, setType, "-exist")
+}
+
+func (*IPTables) DestroySet(name string) error {
+	return runIPSet("destroy", name)
+}
+
+func (*IPTables) AddEntry(setName, entry string) error {
+	return runIPSet("add", setName, entry, "-exist")
+}
+
+func (*IPTables) DeleteEntry(setName, entry string) error {
+	return runIPSet("del", setName, entry)
+}
+
+func (*IPTables) ListSets() ([]string, error) {
+	out, err := exec.Command("ipset", "list", "-name").Output() //#nosec
+	if err != nil {
+		return nil, fmt.Errorf("cannot list ipsets: %w", err)
+	}
+	var sets []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			sets = append(sets, line)
+		}
+	}
+	return sets, nil
+}
+
+func runIPTables(args ...string) error {
+	if out, err := exec.Command("iptables", args...).CombinedOutput(); err != nil { //#nosec
+		return fmt.Errorf("iptables %s failed: %w: %s", strings.Join(args, " "), err, out)
+	}
+	return nil
+}
+
+func runIPSet(args ...string) error {
+	if out, err := exec.Command("ipset", args...).CombinedOutput(); err != nil { //#nosec
+		return fmt.Errorf("ipset %s failed: %w: %s", strings.Join(args, " "), err, out)
+	}
+	return nil
+}
@@ -47,6 +47,20 @@ func (mr *MockNetfilterMockRecorder) AddChain(arg0, arg1 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddChain", reflect.TypeOf((*MockNetfilter)(nil).AddChain), arg0, arg1)
 }
 
+// AddEntry mocks base method.
+func (m *MockNetfilter) AddEntry(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddEntry", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddEntry indicates an expected call of AddEntry.
+func (mr *MockNetfilterMockRecorder) AddEntry(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddEntry", reflect.TypeOf((*MockNetfilter)(nil).AddEntry), arg0, arg1)
+}
+
 // AddRule mocks base method.
 func (m *MockNetfilter) AddRule(arg0, arg1, arg2 string) error {
 	m.ctrl.T.Helper()
@@ -61,6 +75,20 @@ func (mr *MockNetfilterMockRecorder) AddRule(arg0, arg1, arg2 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRule", reflect.TypeOf((*MockNetfilter)(nil).AddRule), arg0, arg1, arg2)
 }
 
+// AddRuleWithSet mocks base method.
+func (m *MockNetfilter) AddRuleWithSet(arg0, arg1, arg2 string, arg3 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddRuleWithSet", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddRuleWithSet indicates an expected call of AddRuleWithSet.
+func (mr *MockNetfilterMockRecorder) AddRuleWithSet(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRuleWithSet", reflect.TypeOf((*MockNetfilter)(nil).AddRuleWithSet), arg0, arg1, arg2, arg3)
+}
+
 // AddTable mocks base method.
 func (m *MockNetfilter) AddTable(arg0 string) error {
 	m.ctrl.T.Helper()
@@ -75,6 +103,34 @@ func (mr *MockNetfilterMockRecorder) AddTable(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTable", reflect.TypeOf((*MockNetfilter)(nil).AddTable), arg0)
 }
 
+// Backend mocks base method.
+func (m *MockNetfilter) Backend() Backend {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Backend")
+	ret0, _ := ret[0].(Backend)
+	return ret0
+}
+
+// Backend indicates an expected call of Backend.
+func (mr *MockNetfilterMockRecorder) Backend() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Backend", reflect.TypeOf((*MockNetfilter)(nil).Backend))
+}
+
+// CreateSet mocks base method.
+func (m *MockNetfilter) CreateSet(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSet", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSet indicates an expected call of CreateSet.
+func (mr *MockNetfilterMockRecorder) CreateSet(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSet", reflect.TypeOf((*MockNetfilter)(nil).CreateSet), arg0, arg1)
+}
+
 // DeleteChain mocks base method.
 func (m *MockNetfilter) DeleteChain(arg0, arg1 string) error {
 	m.ctrl.T.Helper()
@@ -89,6 +145,20 @@ func (mr *MockNetfilterMockRecorder) DeleteChain(arg0, arg1 interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteChain", reflect.TypeOf((*MockNetfilter)(nil).DeleteChain), arg0, arg1)
 }
 
+// DeleteEntry mocks base method.
+func (m *MockNetfilter) DeleteEntry(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteEntry", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteEntry indicates an expected call of DeleteEntry.
+func (mr *MockNetfilterMockRecorder) DeleteEntry(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEntry", reflect.TypeOf((*MockNetfilter)(nil).DeleteEntry), arg0, arg1)
+}
+
 // DeleteTable mocks base method.
 func (m *MockNetfilter) DeleteTable(arg0 string) error {
 	m.ctrl.T.Helper()
@@ -102,3 +172,32 @@ func (mr *MockNetfilterMockRecorder) DeleteTable(arg0 interface{}) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTable", reflect.TypeOf((*MockNetfilter)(nil).DeleteTable), arg0)
 }
+
+// DestroySet mocks base method.
+func (m *MockNetfilter) DestroySet(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DestroySet", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DestroySet indicates an expected call of DestroySet.
+func (mr *MockNetfilterMockRecorder) DestroySet(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DestroySet", reflect.TypeOf((*MockNetfilter)(nil).DestroySet), arg0)
+}
+
+// ListSets mocks base method.
+func (m *MockNetfilter) ListSets() ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSets")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSets indicates an expected call of ListSets.
+func (mr *MockNetfilterMockRecorder) ListSets() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSets", reflect.TypeOf((*MockNetfilter)(nil).ListSets))
+}
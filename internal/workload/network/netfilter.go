@@ -0,0 +1,48 @@
+package network
+
+// Netfilter abstracts the firewall backend used to enforce a workload's
+// network policy: tables, chains and rules, plus ipset-backed sets so a
+// rule that matches against many peers stays a single rule (`-m set
+// --match-set <name> src -j ACCEPT`) instead of growing one rule per peer.
+//
+//go:generate mockgen -destination=mock_netfilter.go -package=network . Netfilter
+type Netfilter interface {
+	AddTable(name string) error
+	DeleteTable(name string) error
+	AddChain(table, chain string) error
+	DeleteChain(table, chain string) error
+	AddRule(table, chain, spec string) error
+
+	// AddRuleWithSet adds a rule built from spec with a "-m set --match-set
+	// <name> src" clause appended for each name in sets, so callers don't
+	// have to hand-build the match-set clauses themselves.
+	AddRuleWithSet(table, chain, spec string, sets []string) error
+
+	// Backend identifies which firewall implementation this Netfilter talks
+	// to, so a caller building rule specs by hand (rather than through
+	// AddRule) knows which syntax to emit.
+	Backend() Backend
+
+	IPSet
+}
+
+// Backend identifies a concrete Netfilter implementation.
+type Backend string
+
+const (
+	// BackendIPTables speaks the legacy iptables/ipset command-line tools.
+	BackendIPTables Backend = "iptables"
+	// BackendNFTables speaks nftables natively over netlink.
+	BackendNFTables Backend = "nftables"
+)
+
+// IPSet manages the ipset-backed sets referenced from
+// Netfilter.AddRuleWithSet, so a workload's per-peer allow/deny list can be
+// expressed as set membership and kept bounded regardless of peer count.
+type IPSet interface {
+	CreateSet(name, setType string) error
+	DestroySet(name string) error
+	AddEntry(setName, entry string) error
+	DeleteEntry(setName, entry string) error
+	ListSets() ([]string, error)
+}
@@ -0,0 +1,458 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+)
+
+// NFTables is a Netfilter implementation that speaks nftables natively over
+// netlink via github.com/google/nftables, for hosts where nftables is the
+// default and iptables-nft (a shim over the same netlink API) would
+// otherwise risk corrupting rule ordering if mixed with a second, real
+// iptables-legacy backend.
+type NFTables struct {
+	conn *nftables.Conn
+
+	lock   sync.Mutex
+	tables map[string]*nftables.Table
+	chains map[string]*nftables.Chain
+	sets   map[string]*nftables.Set
+}
+
+// NewNFTables builds an NFTables backend using the default netlink
+// connection.
+func NewNFTables() (*NFTables, error) {
+	conn, err := nftables.New()
+	if err != nil {
+		return nil, fmt.Errorf("cannot open nftables netlink connection: %w", err)
+	}
+	return &NFTables{
+		conn:   conn,
+		tables: make(map[string]*nftables.Table),
+		chains: make(map[string]*nftables.Chain),
+		sets:   make(map[string]*nftables.Set),
+	}, nil
+}
+
+func (*NFTables) Backend() Backend {
+	return BackendNFTables
+}
+
+func (n *NFTables) AddTable(name string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	table := n.conn.AddTable(&nftables.Table{
+		Name:   name,
+		Family: nftables.TableFamilyIPv4,
+	})
+	if err := n.conn.Flush(); err != nil {
+		return fmt.Errorf("cannot create nftables table %s: %w", name, err)
+	}
+	n.tables[name] = table
+	return nil
+}
+
+func (n *NFTables) DeleteTable(name string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	table, ok := n.tables[name]
+	if !ok {
+		table = &nftables.Table{Name: name, Family: nftables.TableFamilyIPv4}
+	}
+	n.conn.DelTable(table)
+	if err := n.conn.Flush(); err != nil {
+		return fmt.Errorf("cannot delete nftables table %s: %w", name, err)
+	}
+	delete(n.tables, name)
+	return nil
+}
+
+func (n *NFTables) AddChain(table, chain string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	t, ok := n.tables[table]
+	if !ok {
+		return fmt.Errorf("nftables table %s does not exist", table)
+	}
+	c := n.conn.AddChain(&nftables.Chain{
+		Name:  chain,
+		Table: t,
+	})
+	if err := n.conn.Flush(); err != nil {
+		return fmt.Errorf("cannot create nftables chain %s/%s: %w", table, chain, err)
+	}
+	n.chains[chainKey(table, chain)] = c
+	return nil
+}
+
+func (n *NFTables) DeleteChain(table, chain string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	c, ok := n.chains[chainKey(table, chain)]
+	if !ok {
+		return fmt.Errorf("nftables chain %s/%s does not exist", table, chain)
+	}
+	n.conn.DelChain(c)
+	if err := n.conn.Flush(); err != nil {
+		return fmt.Errorf("cannot delete nftables chain %s/%s: %w", table, chain, err)
+	}
+	delete(n.chains, chainKey(table, chain))
+	return nil
+}
+
+// AddRule translates spec, an iptables-rule-spec-shaped string (see
+// translateSpec), into the matching set of nftables
+// expressions. Full parity with arbitrary iptables syntax is out of scope;
+// specs are expected to come from the firewall reconciler, which only ever
+// emits the handful of shapes it needs.
+func (n *NFTables) AddRule(table, chain, spec string) error {
+	return n.addRule(table, chain, spec, nil)
+}
+
+func (n *NFTables) AddRuleWithSet(table, chain, spec string, sets []string) error {
+	return n.addRule(table, chain, spec, sets)
+}
+
+func (n *NFTables) addRule(table, chain, spec string, sets []string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	t, ok := n.tables[table]
+	if !ok {
+		return fmt.Errorf("nftables table %s does not exist", table)
+	}
+	c, ok := n.chains[chainKey(table, chain)]
+	if !ok {
+		return fmt.Errorf("nftables chain %s/%s does not exist", table, chain)
+	}
+
+	matchExprs, verdict, err := translateSpec(spec)
+	if err != nil {
+		return fmt.Errorf("cannot translate rule spec %q: %w", spec, err)
+	}
+	if verdict == nil {
+		return fmt.Errorf("rule spec %q has no verdict (-j target); refusing to install a no-op rule", spec)
+	}
+
+	exprs := append([]expr.Any{}, matchExprs...)
+	for _, setName := range sets {
+		set, ok := n.sets[setName]
+		if !ok {
+			return fmt.Errorf("nftables set %s does not exist", setName)
+		}
+		exprs = append(exprs, matchSetExpr(set)...)
+	}
+	exprs = append(exprs, verdict)
+
+	n.conn.AddRule(&nftables.Rule{
+		Table: t,
+		Chain: c,
+		Exprs: exprs,
+	})
+	if err := n.conn.Flush(); err != nil {
+		return fmt.Errorf("cannot add nftables rule to %s/%s: %w", table, chain, err)
+	}
+	return nil
+}
+
+func (n *NFTables) CreateSet(name, setType string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if _, ok := n.sets[name]; ok {
+		return nil
+	}
+
+	var table *nftables.Table
+	for _, t := range n.tables {
+		table = t
+		break
+	}
+	if table == nil {
+		return fmt.Errorf("cannot create set %s: no nftables table registered yet", name)
+	}
+
+	set := &nftables.Set{
+		Table:   table,
+		Name:    name,
+		KeyType: setKeyType(setType),
+	}
+	if err := n.conn.AddSet(set, nil); err != nil {
+		return fmt.Errorf("cannot create nftables set %s: %w", name, err)
+	}
+	if err := n.conn.Flush(); err != nil {
+		return fmt.Errorf("cannot create nftables set %s: %w", name, err)
+	}
+	n.sets[name] = set
+	return nil
+}
+
+func (n *NFTables) DestroySet(name string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	set, ok := n.sets[name]
+	if !ok {
+		return fmt.Errorf("nftables set %s does not exist", name)
+	}
+	n.conn.DelSet(set)
+	if err := n.conn.Flush(); err != nil {
+		return fmt.Errorf("cannot destroy nftables set %s: %w", name, err)
+	}
+	delete(n.sets, name)
+	return nil
+}
+
+func (n *NFTables) AddEntry(setName, entry string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	set, ok := n.sets[setName]
+	if !ok {
+		return fmt.Errorf("nftables set %s does not exist", setName)
+	}
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return fmt.Errorf("cannot parse ipset entry %q as an IP address", entry)
+	}
+	if err := n.conn.SetAddElements(set, []nftables.SetElement{{Key: ip.To4()}}); err != nil {
+		return fmt.Errorf("cannot add %s to nftables set %s: %w", entry, setName, err)
+	}
+	return n.conn.Flush()
+}
+
+func (n *NFTables) DeleteEntry(setName, entry string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	set, ok := n.sets[setName]
+	if !ok {
+		return fmt.Errorf("nftables set %s does not exist", setName)
+	}
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return fmt.Errorf("cannot parse ipset entry %q as an IP address", entry)
+	}
+	if err := n.conn.SetDeleteElements(set, []nftables.SetElement{{Key: ip.To4()}}); err != nil {
+		return fmt.Errorf("cannot remove %s from nftables set %s: %w", entry, setName, err)
+	}
+	return n.conn.Flush()
+}
+
+func (n *NFTables) ListSets() ([]string, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	names := make([]string, 0, len(n.sets))
+	for name := range n.sets {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func chainKey(table, chain string) string {
+	return table + "/" + chain
+}
+
+func setKeyType(setType string) nftables.SetDatatype {
+	if strings.EqualFold(setType, "hash:net") {
+		return nftables.TypeIPAddr
+	}
+	return nftables.TypeIPAddr
+}
+
+const (
+	ipv4SourceOffset      = 12
+	ipv4DestinationOffset = 16
+	ipv4AddressLen        = 4
+
+	transportSourcePortOffset = 0
+	transportDestPortOffset   = 2
+	portLen                   = 2
+)
+
+// translateSpec translates spec, an iptables-rule-spec-shaped string such as
+// `-p tcp --dport 443 -j ACCEPT`, into the matching nftables match
+// expressions plus its trailing verdict expression. Full parity with
+// arbitrary iptables syntax is out of scope; spec is expected to come from
+// the firewall reconciler, which only ever emits a small, fixed set of
+// shapes. Any token it doesn't recognize is a translation error rather than
+// a silently-dropped match, since a rule missing a match or its verdict
+// would install as something other than what was asked for.
+func translateSpec(spec string) ([]expr.Any, expr.Any, error) {
+	tokens := strings.Fields(spec)
+
+	var exprs []expr.Any
+	var verdict expr.Any
+	var proto string
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		arg := func() (string, error) {
+			if i+1 >= len(tokens) {
+				return "", fmt.Errorf("%s requires an argument", token)
+			}
+			i++
+			return tokens[i], nil
+		}
+
+		switch token {
+		case "-p", "--protocol":
+			p, err := arg()
+			if err != nil {
+				return nil, nil, err
+			}
+			protoExprs, protoNum, err := protocolMatchExpr(p)
+			if err != nil {
+				return nil, nil, err
+			}
+			proto = protoNum
+			exprs = append(exprs, protoExprs...)
+		case "-s", "--source":
+			a, err := arg()
+			if err != nil {
+				return nil, nil, err
+			}
+			addrExprs, err := addressMatchExpr(a, ipv4SourceOffset)
+			if err != nil {
+				return nil, nil, err
+			}
+			exprs = append(exprs, addrExprs...)
+		case "-d", "--destination":
+			a, err := arg()
+			if err != nil {
+				return nil, nil, err
+			}
+			addrExprs, err := addressMatchExpr(a, ipv4DestinationOffset)
+			if err != nil {
+				return nil, nil, err
+			}
+			exprs = append(exprs, addrExprs...)
+		case "--sport":
+			p, err := arg()
+			if err != nil {
+				return nil, nil, err
+			}
+			if proto == "" {
+				return nil, nil, fmt.Errorf("--sport requires -p to be set first")
+			}
+			portExprs, err := portMatchExpr(p, transportSourcePortOffset)
+			if err != nil {
+				return nil, nil, err
+			}
+			exprs = append(exprs, portExprs...)
+		case "--dport":
+			p, err := arg()
+			if err != nil {
+				return nil, nil, err
+			}
+			if proto == "" {
+				return nil, nil, fmt.Errorf("--dport requires -p to be set first")
+			}
+			portExprs, err := portMatchExpr(p, transportDestPortOffset)
+			if err != nil {
+				return nil, nil, err
+			}
+			exprs = append(exprs, portExprs...)
+		case "-j", "--jump":
+			target, err := arg()
+			if err != nil {
+				return nil, nil, err
+			}
+			v, err := verdictExpr(target)
+			if err != nil {
+				return nil, nil, err
+			}
+			verdict = v
+		default:
+			return nil, nil, fmt.Errorf("unsupported rule spec token %q", token)
+		}
+	}
+
+	return exprs, verdict, nil
+}
+
+// protocolMatchExpr matches the IPv4 header's protocol field against proto,
+// returning the resolved protocol name alongside the expressions so --sport/
+// --dport can be rejected when no protocol was set first.
+func protocolMatchExpr(proto string) ([]expr.Any, string, error) {
+	var num byte
+	switch strings.ToLower(proto) {
+	case "tcp":
+		num = unix.IPPROTO_TCP
+	case "udp":
+		num = unix.IPPROTO_UDP
+	default:
+		return nil, "", fmt.Errorf("unsupported protocol %q", proto)
+	}
+	return []expr.Any{
+		&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{num}},
+	}, strings.ToLower(proto), nil
+}
+
+// addressMatchExpr matches the IPv4 header field at offset (source or
+// destination) against addr, which may be a bare address or a CIDR.
+func addressMatchExpr(addr string, offset uint32) ([]expr.Any, error) {
+	ip, ipNet, err := net.ParseCIDR(addr)
+	if err != nil {
+		ip = net.ParseIP(addr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid address %q", addr)
+		}
+		ipNet = &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)}
+	}
+	return []expr.Any{
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: offset, Len: ipv4AddressLen},
+		&expr.Bitwise{SourceRegister: 1, DestRegister: 1, Len: ipv4AddressLen, Mask: ipNet.Mask, Xor: make([]byte, ipv4AddressLen)},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: ipNet.IP.To4()},
+	}, nil
+}
+
+// portMatchExpr matches the transport header field at offset (source or
+// destination port) against port.
+func portMatchExpr(port string, offset uint32) ([]expr.Any, error) {
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", port)
+	}
+	return []expr.Any{
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: offset, Len: portLen},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: binaryutil.BigEndian.PutUint16(uint16(p))},
+	}, nil
+}
+
+// verdictExpr translates a -j target into its nftables verdict expression.
+func verdictExpr(target string) (expr.Any, error) {
+	switch strings.ToUpper(target) {
+	case "ACCEPT":
+		return &expr.Verdict{Kind: expr.VerdictAccept}, nil
+	case "DROP":
+		return &expr.Verdict{Kind: expr.VerdictDrop}, nil
+	case "REJECT":
+		return &expr.Reject{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported jump target %q", target)
+	}
+}
+
+// matchSetExpr loads the IPv4 source address into a register and matches it
+// against set, the nftables equivalent of iptables' `-m set --match-set`.
+func matchSetExpr(set *nftables.Set) []expr.Any {
+	return []expr.Any{
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: ipv4SourceOffset, Len: ipv4AddressLen},
+		&expr.Lookup{SourceRegister: 1, SetName: set.Name},
+	}
+}
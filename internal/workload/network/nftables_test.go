@@ -0,0 +1,43 @@
+package network
+
+import "testing"
+
+func TestTranslateSpecAcceptVerdict(t *testing.T) {
+	exprs, verdict, err := translateSpec("-p tcp --dport 443 -j ACCEPT")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(exprs) == 0 {
+		t.Fatalf("expected match expressions, got none")
+	}
+	if verdict == nil {
+		t.Fatalf("expected a verdict expression, got nil")
+	}
+}
+
+func TestTranslateSpecUnsupportedTokenErrors(t *testing.T) {
+	_, _, err := translateSpec("-m conntrack --ctstate ESTABLISHED -j ACCEPT")
+	if err == nil {
+		t.Fatalf("expected an error for an unsupported token, got none")
+	}
+}
+
+func TestTranslateSpecMissingVerdictReturnsNilVerdict(t *testing.T) {
+	exprs, verdict, err := translateSpec("-p tcp --dport 443")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if verdict != nil {
+		t.Fatalf("expected no verdict when spec has no -j, got %v", verdict)
+	}
+	if len(exprs) == 0 {
+		t.Fatalf("expected match expressions, got none")
+	}
+}
+
+func TestTranslateSpecDportWithoutProtocolErrors(t *testing.T) {
+	_, _, err := translateSpec("--dport 443 -j ACCEPT")
+	if err == nil {
+		t.Fatalf("expected an error when --dport is used without -p")
+	}
+}
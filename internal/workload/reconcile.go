@@ -0,0 +1,79 @@
+package workload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"path"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const reconcileHashFileName = ".reconcile-hash"
+
+// computeReconcileHash hashes everything about a workload that should force
+// a Remove+Run when it changes: its rendered manifest (pod spec,
+// annotations, labels and configmaps, via podYaml), its authfile bytes, and
+// the current content hash of every secret it references, so a secret
+// rotation invalidates the cache even though podYaml itself didn't change.
+func (w *WorkloadManager) computeReconcileHash(podYaml []byte, authFile string, pod *v1.Pod) string {
+	h := sha256.New()
+	h.Write(podYaml)
+	h.Write([]byte(authFile))
+	for _, name := range referencedSecretNames(pod) {
+		h.Write([]byte(name))
+		h.Write([]byte(w.secretHashes[name]))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (w *WorkloadManager) getReconcileHashPath(workloadName string) string {
+	return path.Join(w.getWorkloadDirPath(workloadName), reconcileHashFileName)
+}
+
+func (w *WorkloadManager) loadReconcileHash(workloadName string) (string, error) {
+	data, err := ioutil.ReadFile(w.getReconcileHashPath(workloadName)) //#nosec
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (w *WorkloadManager) storeReconcileHash(workloadName, hash string) error {
+	return w.storeFile(w.getReconcileHashPath(workloadName), []byte(hash))
+}
+
+// referencedSecretNames returns, in sorted order for a stable hash, every
+// distinct secret name pod consumes via env.valueFrom.secretKeyRef,
+// envFrom.secretRef, volumes[*].secret.secretName or imagePullSecrets.
+func referencedSecretNames(pod *v1.Pod) []string {
+	seen := make(map[string]struct{})
+	for _, ref := range pod.Spec.ImagePullSecrets {
+		seen[ref.Name] = struct{}{}
+	}
+	for _, vol := range pod.Spec.Volumes {
+		if vol.Secret != nil {
+			seen[vol.Secret.SecretName] = struct{}{}
+		}
+	}
+	for _, container := range pod.Spec.Containers {
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				seen[env.ValueFrom.SecretKeyRef.Name] = struct{}{}
+			}
+		}
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil {
+				seen[envFrom.SecretRef.Name] = struct{}{}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,150 @@
+package workload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"git.sr.ht/~spc/go-log"
+	"github.com/project-flotta/flotta-operator/models"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	secretHashesFileName = "secret-hashes.json"
+
+	// WorkloadRestartedForSecretRotation is the event reason emitted when a
+	// workload is force-restarted because a secret it references changed
+	// content, even though the workload's own spec is unchanged.
+	WorkloadRestartedForSecretRotation = "WorkloadRestartedForSecretRotation"
+)
+
+// reconcileSecretHashes hashes each configured secret's data, compares it
+// against the hash stored from the previous Update, and returns the set of
+// secret names whose content actually changed. The new hashes are persisted
+// immediately, so a restart mid-rollout doesn't lose track of what was
+// already seen.
+func (w *WorkloadManager) reconcileSecretHashes(configSecrets models.SecretList) map[string]struct{} {
+	changed := make(map[string]struct{})
+	for _, configSecret := range configSecrets {
+		hash := hashSecretData(configSecret.Data)
+		if prev, ok := w.secretHashes[configSecret.Name]; !ok || prev != hash {
+			changed[configSecret.Name] = struct{}{}
+		}
+		w.secretHashes[configSecret.Name] = hash
+	}
+
+	if err := w.storeSecretHashes(); err != nil {
+		log.Errorf("cannot persist secret hashes. DeviceID: %s; err: %v", w.deviceId, err)
+	}
+
+	return changed
+}
+
+func hashSecretData(data map[string]string) string {
+	// json.Marshal sorts map keys, so the hash is stable regardless of the
+	// order the operator sent the secret's keys in.
+	b, _ := json.Marshal(data)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func (w *WorkloadManager) loadSecretHashes() map[string]string {
+	hashes := make(map[string]string)
+	data, err := ioutil.ReadFile(w.secretHashesPath) //#nosec
+	if err != nil {
+		return hashes
+	}
+	if err := json.Unmarshal(data, &hashes); err != nil {
+		return make(map[string]string)
+	}
+	return hashes
+}
+
+func (w *WorkloadManager) storeSecretHashes() error {
+	data, err := json.Marshal(w.secretHashes)
+	if err != nil {
+		return err
+	}
+	return w.storeFile(w.secretHashesPath, data)
+}
+
+// restartWorkloadsForSecretRotation force-restarts every configured workload
+// that references one of changedSecrets and wasn't already redeployed
+// earlier in this Update (for any reason, including a reconcile hash that
+// changed because it covers the referenced secrets), so a rotated secret
+// reaches a running pod even though the pod's manifest is otherwise unchanged.
+func (w *WorkloadManager) restartWorkloadsForSecretRotation(workloads []*models.Workload, changedSecrets map[string]struct{}, alreadyReconciled map[string]struct{}) {
+	for _, workload := range workloads {
+		if _, done := alreadyReconciled[workload.Name]; done {
+			continue
+		}
+
+		manifestPath := w.getManifestPath(workload.Name)
+		manifestBytes, err := ioutil.ReadFile(manifestPath) //#nosec
+		if err != nil {
+			continue
+		}
+
+		pod := v1.Pod{}
+		if err := yaml.Unmarshal(manifestDocument(manifestBytes), &pod); err != nil {
+			log.Errorf("cannot parse manifest for workload %s while checking secret rotation: %v", workload.Name, err)
+			continue
+		}
+
+		if !podReferencesSecrets(&pod, changedSecrets) {
+			continue
+		}
+
+		authFilePath := w.getAuthFilePath(workload.Name)
+		runAuthFilePath := authFilePath
+		if _, err := os.Stat(authFilePath); err != nil {
+			runAuthFilePath = ""
+		}
+
+		if err := w.workloads.Remove(workload.Name); err != nil {
+			log.Errorf("error removing workload %s for secret rotation. DeviceID: %s; err: %v", workload.Name, w.deviceId, err)
+			continue
+		}
+		if err := w.workloads.Run(&pod, manifestPath, runAuthFilePath, pod.Annotations); err != nil {
+			log.Errorf("cannot restart workload %s for secret rotation. DeviceID: %s; err: %v", workload.Name, w.deviceId, err)
+			continue
+		}
+
+		var authFile string
+		if runAuthFilePath != "" {
+			if data, err := ioutil.ReadFile(runAuthFilePath); err == nil { //#nosec
+				authFile = string(data)
+			}
+		}
+		reconcileHash := w.computeReconcileHash(manifestBytes, authFile, &pod)
+		if err := w.storeReconcileHash(workload.Name, reconcileHash); err != nil {
+			log.Errorf("cannot persist reconcile hash for workload %s. DeviceID: %s; err: %v", workload.Name, w.deviceId, err)
+		}
+
+		w.restartHealthMonitors(workload.Name, &pod)
+		w.emitEvent(workload.Name, EventTypeNormal, WorkloadRestartedForSecretRotation, fmt.Sprintf(
+			"restarted workload '%s' because a referenced secret changed", workload.Name))
+	}
+}
+
+// podReferencesSecrets reports whether pod mounts or consumes any of
+// changedSecrets via env.valueFrom.secretKeyRef, envFrom.secretRef,
+// volumes[*].secret.secretName, or imagePullSecrets.
+func podReferencesSecrets(pod *v1.Pod, changedSecrets map[string]struct{}) bool {
+	for _, name := range referencedSecretNames(pod) {
+		if _, ok := changedSecrets[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func secretHashesPath(dataDir string) string {
+	return path.Join(dataDir, secretHashesFileName)
+}